@@ -0,0 +1,52 @@
+package gomysqllock
+
+import "time"
+
+// WithAdaptiveRefresh lets each lock's refresher adapt its interval between min and max: it is halved after a
+// heartbeat slower than the health threshold (see WithHealthThreshold), so that a degrading connection is checked more
+// often, and grows back by a quarter after each fast heartbeat (under half of the threshold). Refreshers start at the
+// refresh interval, and every ping may take up to max. A min under a millisecond is raised to it, and a max under min
+// is raised to min; a max of 0 disables adaptive refresh. The same bounds apply when it is given to Reconfigure.
+func WithAdaptiveRefresh(min, max time.Duration) lockerOpt {
+	return func(l *MysqlLocker) {
+		l.adaptiveRefreshMin, l.adaptiveRefreshMax = adaptiveRefreshBounds(min, max)
+	}
+}
+
+// minAdaptiveRefresh is the shortest interval adaptive refresh may use, shorter ones would make refreshers spin
+const minAdaptiveRefresh = time.Millisecond
+
+// adaptiveRefreshBounds returns the bounds WithAdaptiveRefresh uses, raising min to minAdaptiveRefresh and max to min
+func adaptiveRefreshBounds(min, max time.Duration) (time.Duration, time.Duration) {
+	if max <= 0 {
+		return 0, 0
+	}
+	if min < minAdaptiveRefresh {
+		min = minAdaptiveRefresh
+	}
+	if max < min {
+		max = min
+	}
+	return min, max
+}
+
+// nextRefreshInterval returns the refresh interval to use after a heartbeat which took latency
+func nextRefreshInterval(interval, latency, threshold, min, max time.Duration) time.Duration {
+	switch {
+	case latency > threshold:
+		interval /= 2
+	case latency < threshold/2:
+		interval += interval / 4
+	}
+	return clampDuration(interval, min, max)
+}
+
+func clampDuration(d, min, max time.Duration) time.Duration {
+	if d < min {
+		return min
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
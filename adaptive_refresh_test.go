@@ -0,0 +1,42 @@
+package gomysqllock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextRefreshInterval(t *testing.T) {
+	min, max := time.Millisecond*100, time.Second*2
+	threshold := time.Millisecond * 500
+
+	assert.Equal(t, time.Millisecond*500, nextRefreshInterval(time.Second, time.Second, threshold, min, max),
+		"slow heartbeats shall shorten the interval")
+	assert.Equal(t, min, nextRefreshInterval(time.Millisecond*150, time.Second, threshold, min, max))
+
+	assert.Equal(t, time.Millisecond*1250, nextRefreshInterval(time.Second, time.Millisecond, threshold, min, max),
+		"fast heartbeats shall relax the interval")
+	assert.Equal(t, max, nextRefreshInterval(time.Millisecond*1900, time.Millisecond, threshold, min, max))
+
+	assert.Equal(t, time.Second, nextRefreshInterval(time.Second, time.Millisecond*300, threshold, min, max))
+}
+
+func TestAdaptiveRefreshBounds(t *testing.T) {
+	min, max := adaptiveRefreshBounds(time.Nanosecond, time.Second)
+	assert.Equal(t, minAdaptiveRefresh, min, "too short a min shall be raised, refreshers would spin")
+	assert.Equal(t, time.Second, max)
+
+	min, max = adaptiveRefreshBounds(time.Second*2, time.Second)
+	assert.Equal(t, time.Second*2, min)
+	assert.Equal(t, time.Second*2, max, "a max under min shall be raised to it")
+
+	min, max = adaptiveRefreshBounds(time.Second, 0)
+	assert.False(t, liveConfig{adaptiveRefreshMin: min, adaptiveRefreshMax: max}.adaptive())
+
+	locker := NewMysqlLocker(nil)
+	assert.NoError(t, locker.Reconfigure(WithAdaptiveRefresh(0, time.Nanosecond)))
+	config := locker.config()
+	assert.Equal(t, minAdaptiveRefresh, config.adaptiveRefreshMin, "Reconfigure shall apply the same bounds")
+	assert.Equal(t, minAdaptiveRefresh, config.adaptiveRefreshMax)
+}
@@ -0,0 +1,44 @@
+package gomysqllock
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// beforeReleaseRetryInterval is the pause between calls of a BeforeRelease hook which vetoed the release
+const beforeReleaseRetryInterval = 50 * time.Millisecond
+
+// WithBeforeRelease sets a hook which runs before held locks are released, for example to confirm that the write the
+// lock protects is durable. While the hook returns an error the release is vetoed and the hook is called again, for
+// at most maxDelay: the lock is released then anyway, and Release returns the hook's last error. The hook's context
+// is done once maxDelay has passed, or earlier when the release is bounded, see Lock.ReleaseDetached. Lost locks,
+// which can not be released anymore, do not run the hook.
+func WithBeforeRelease(hook func(ctx context.Context, lock *Lock) error, maxDelay time.Duration) lockerOpt {
+	return func(l *MysqlLocker) {
+		l.beforeRelease = hook
+		l.beforeReleaseMaxDelay = maxDelay
+	}
+}
+
+// runBeforeRelease calls the BeforeRelease hook until it lets the release go ahead, its delay is exhausted or ctx is
+// done, and returns the veto still standing then
+func (l *Lock) runBeforeRelease(ctx context.Context) error {
+	if l.locker.beforeRelease == nil || l.degraded || atomic.LoadInt32(&l.lost) == 1 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, l.locker.beforeReleaseMaxDelay)
+	defer cancel()
+
+	for {
+		err := l.locker.beforeRelease(ctx, l)
+		if err == nil {
+			return nil
+		}
+		if sleepContext(ctx, beforeReleaseRetryInterval) != nil {
+			return fmt.Errorf("released despite veto: %w", err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package gomysqllock
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// DefaultCanaryInterval is the interval of the probes configured with WithCanary, when the given one is not positive
+const DefaultCanaryInterval = 30 * time.Second
+
+// WithCanary continuously verifies the locking path: every interval, the locker obtains and releases the probe key in
+// the background and calls hook with the round trip's duration and error (nil on success), so that a broken locking
+// path shows up before a real job needs it. A suffix derived from the locker's owner (see WithOwner) is appended to the
+// probe key, so that instances sharing a key do not fail each other's probes; processes configured with the same owner
+// need distinct keys. The suffix takes 9 of the 64 characters allowed in lock names. Probes go through the locker's
+// guards and middlewares like any lock, but are kept out of the history table, the acquire SLO, the pin stats hook and
+// failing open, so that they do not skew what those report. Intervals which are not positive are replaced with
+// DefaultCanaryInterval. The canary stops when the locker is shut down.
+func WithCanary(key string, interval time.Duration, hook func(latency time.Duration, err error)) lockerOpt {
+	return func(l *MysqlLocker) {
+		if interval <= 0 {
+			interval = DefaultCanaryInterval
+		}
+		l.canaryKey = key
+		l.canaryInterval = interval
+		l.canaryHook = hook
+	}
+}
+
+// canaryKeySuffix returns the suffix appended to the probe key of processes with the given owner
+func canaryKeySuffix(owner string) string {
+	sum := sha256.Sum256([]byte(owner))
+	return "@" + hex.EncodeToString(sum[:4])
+}
+
+// isCanary reports whether key is the canary's probe key
+func (l MysqlLocker) isCanary(key string) bool {
+	return l.canaryHook != nil && key == l.canaryKey
+}
+
+// runCanary runs the probes configured with WithCanary until the locker is shut down
+func (l MysqlLocker) runCanary() {
+	l.state.goroutine(func() {
+		ticker := time.NewTicker(l.canaryInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				latency, err := l.probe()
+				if err == ErrLockerShutdown {
+					return
+				}
+				l.canaryHook(latency, err)
+			case <-l.state.done:
+				return
+			}
+		}
+	})
+}
+
+// probe obtains and releases the canary key, giving up after the canary interval
+func (l MysqlLocker) probe() (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), l.canaryInterval)
+	defer cancel()
+
+	start := time.Now()
+	lock, err := l.ObtainTimeoutContext(ctx, l.canaryKey, 0)
+	if err != nil {
+		return time.Since(start), err
+	}
+	if lock.Degraded() {
+		lock.Release()
+		return time.Since(start), ErrLockDegraded
+	}
+	err = lock.ReleaseDetached()
+	return time.Since(start), err
+}
@@ -0,0 +1,31 @@
+package gomysqllock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithCanary(t *testing.T) {
+	var locker MysqlLocker
+	assert.False(t, locker.isCanary(""), "keys shall not be probes without a canary")
+
+	WithCanary("probe", 0, func(latency time.Duration, err error) {})(&locker)
+	assert.Equal(t, DefaultCanaryInterval, locker.canaryInterval)
+	assert.True(t, locker.isCanary("probe"))
+	assert.False(t, locker.isCanary("job"))
+}
+
+func TestCanaryKeySuffix(t *testing.T) {
+	first := NewMysqlLocker(nil, WithOwner("first"), WithCanary("probe", time.Hour, func(time.Duration, error) {}))
+	second := NewMysqlLocker(nil, WithCanary("probe", time.Hour, func(time.Duration, error) {}), WithOwner("second"))
+	defer first.Shutdown(context.Background())
+	defer second.Shutdown(context.Background())
+
+	assert.Equal(t, "probe"+canaryKeySuffix("first"), first.canaryKey)
+	assert.Equal(t, "probe"+canaryKeySuffix("second"), second.canaryKey, "the suffix shall use the final owner")
+	assert.NotEqual(t, first.canaryKey, second.canaryKey, "owners shall probe distinct keys")
+	assert.Len(t, canaryKeySuffix("first"), 9)
+}
@@ -0,0 +1,33 @@
+package gomysqllock
+
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL error numbers telling that a feature is missing or not allowed
+const (
+	mysqlErrFunctionDoesNotExist = 1305 // ER_SP_DOES_NOT_EXIST
+	mysqlErrNotSupportedYet      = 1235 // ER_NOT_SUPPORTED_YET
+	mysqlErrTableAccessDenied    = 1142 // ER_TABLEACCESS_DENIED_ERROR
+	mysqlErrSpecificAccessDenied = 1227 // ER_SPECIFIC_ACCESS_DENIED_ERROR
+	mysqlErrProcAccessDenied     = 1370 // ER_PROCACCESS_DENIED_ERROR
+	mysqlErrKillDenied           = 1095 // ER_KILL_DENIED_ERROR
+)
+
+// unsupportedError returns an ErrUnsupportedByServer for the feature if err tells that the server lacks it or denies
+// it, nil otherwise
+func unsupportedError(feature string, err error) *ErrUnsupportedByServer {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return nil
+	}
+
+	switch mysqlErr.Number {
+	case mysqlErrFunctionDoesNotExist, mysqlErrNotSupportedYet, mysqlErrTableAccessDenied,
+		mysqlErrSpecificAccessDenied, mysqlErrProcAccessDenied, mysqlErrKillDenied:
+		return &ErrUnsupportedByServer{Feature: feature, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package gomysqllock
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnsupportedError(t *testing.T) {
+	serverErr := &mysql.MySQLError{Number: 1305, Message: "FUNCTION IS_USED_LOCK does not exist"}
+
+	err := error(unsupportedError("IS_USED_LOCK", fmt.Errorf("wrapped: %w", serverErr)))
+	var unsupportedErr *ErrUnsupportedByServer
+	assert.True(t, errors.As(err, &unsupportedErr))
+	assert.Equal(t, "IS_USED_LOCK", unsupportedErr.Feature)
+	assert.True(t, errors.Is(err, serverErr))
+
+	assert.NotNil(t, unsupportedError("KILL", &mysql.MySQLError{Number: 1095}), "denied kills shall be reported")
+	assert.Nil(t, unsupportedError("IS_USED_LOCK", &mysql.MySQLError{Number: 1064}))
+	assert.Nil(t, unsupportedError("IS_USED_LOCK", errors.New("not a server error")))
+}
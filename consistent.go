@@ -0,0 +1,36 @@
+package gomysqllock
+
+import (
+	"context"
+	"fmt"
+)
+
+// IsLockedConsistent is IsLockedContext for callers which need to read their own locks: a key held through this
+// locker is reported locked right away, from the in-process registry, unless the lock is being released or was lost.
+// Other keys are checked within a read-write
+// transaction, which proxies splitting reads from writes route to the primary, so that a key just released by this
+// process is not reported as still locked by a lagging or misrouted read.
+func (l MysqlLocker) IsLockedConsistent(ctx context.Context, key string) (bool, error) {
+	if err := l.validateKey(key); err != nil {
+		return false, err
+	}
+
+	for _, lock := range l.HeldLocks() {
+		if lock.key == key && lock.GetContext().Err() == nil {
+			return true, nil
+		}
+	}
+
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin a transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var res int
+	err = tx.QueryRowContext(ctx, isUsedLockQuery, l.lockName(key)).Scan(&res)
+	if err != nil {
+		return false, isLockedError(ctx, key, err)
+	}
+	return res != -1, nil
+}
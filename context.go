@@ -0,0 +1,11 @@
+package gomysqllock
+
+import "context"
+
+type lockKeyContextKey struct{}
+
+// KeyFromContext returns the key of the lock whose context (as returned by Lock.GetContext) is ctx or derived from it
+func KeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(lockKeyContextKey{}).(string)
+	return key, ok
+}
@@ -0,0 +1,34 @@
+// Package cronlock provides robfig/cron job wrappers which use go-mysql-lock so that a scheduled job never runs on more
+// than one instance at a time
+package cronlock
+
+import (
+	"github.com/robfig/cron/v3"
+	gomysqllock "github.com/sanketplus/go-mysql-lock"
+)
+
+// KeyPrefix is prepended to entry names to derive their lock keys
+const KeyPrefix = "cron:"
+
+// SkipIfLocked returns a cron.JobWrapper which runs the wrapped job only if the lock derived from name can be obtained
+// without waiting. Runs are skipped while any instance (including this one) is still running the same entry.
+// onSkip, if not nil, is called with the lock key and the reason of every skipped run.
+func SkipIfLocked(locker *gomysqllock.MysqlLocker, name string, onSkip func(key string, err error)) cron.JobWrapper {
+	key := KeyPrefix + name
+
+	return func(j cron.Job) cron.Job {
+		return cron.FuncJob(func() {
+			// a zero MySQL timeout makes GET_LOCK give up immediately when the lock is held
+			lock, err := locker.ObtainTimeout(key, 0)
+			if err != nil {
+				if onSkip != nil {
+					onSkip(key, err)
+				}
+				return
+			}
+			defer lock.Release()
+
+			j.Run()
+		})
+	}
+}
@@ -0,0 +1,42 @@
+// +build !oldmysql
+
+package cronlock
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/robfig/cron/v3"
+	gomysqllock "github.com/sanketplus/go-mysql-lock"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLocker(t *testing.T) *gomysqllock.MysqlLocker {
+	db, err := sql.Open("mysql", "root@tcp(localhost:3306)/")
+	assert.NoError(t, err, "failed to setup db")
+	return gomysqllock.NewMysqlLocker(db)
+}
+
+func TestSkipIfLocked(t *testing.T) {
+	locker := setupLocker(t)
+	runs := 0
+	var skipped error
+
+	job := cron.NewChain(SkipIfLocked(locker, "job", func(_ string, err error) { skipped = err })).
+		Then(cron.FuncJob(func() { runs++ }))
+
+	job.Run()
+	assert.Equal(t, 1, runs)
+	assert.NoError(t, skipped)
+
+	// another instance is running the entry
+	lock, err := locker.Obtain(KeyPrefix + "job")
+	assert.NoError(t, err, "failed to obtain lock")
+
+	job.Run()
+	assert.Equal(t, 1, runs)
+	assert.Equal(t, gomysqllock.ErrMySQLTimeout, skipped)
+
+	assert.NoError(t, lock.Release())
+}
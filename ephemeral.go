@@ -0,0 +1,22 @@
+package gomysqllock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// ephemeralSuffixBytes is the number of random bytes in the suffix of ephemeral keys
+const ephemeralSuffixBytes = 8
+
+// ObtainEphemeral obtains a lock on a new, unique key made of keyPrefix and a random suffix, for patterns like "one
+// lock per in-flight operation". The generated key is available from the lock's Key method.
+func (l MysqlLocker) ObtainEphemeral(ctx context.Context, keyPrefix string) (*Lock, error) {
+	suffix := make([]byte, ephemeralSuffixBytes)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("failed to generate an ephemeral key: %w", err)
+	}
+
+	return l.ObtainContext(ctx, keyPrefix+hex.EncodeToString(suffix))
+}
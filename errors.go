@@ -1,6 +1,9 @@
 package gomysqllock
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // ErrGetLockContextCancelled is returned when user given context is cancelled while trying to obtain the lock
 var ErrGetLockContextCancelled = errors.New("context cancelled while trying to obtain lock")
@@ -10,3 +13,131 @@ var ErrMySQLTimeout = errors.New("(mysql) timeout while acquiring the lock")
 
 // ErrMySQLInternalError is returned when MySQL is returning a generic internal error
 var ErrMySQLInternalError = errors.New("internal mysql error acquiring the lock")
+
+// ErrInvalidRoute is returned when the routing function of a RoutedLocker maps a key to an unknown primary
+var ErrInvalidRoute = errors.New("routing function returned an unknown primary")
+
+// ErrMetadataNotConfigured is returned by metadata operations when the locker has no metadata table configured
+var ErrMetadataNotConfigured = errors.New("lock metadata table is not configured")
+
+// ErrNoHolderMetadata is returned when a key is not locked or its holder did not attach any metadata
+var ErrNoHolderMetadata = errors.New("lock holder has no metadata")
+
+// KeyError is returned when a key can not be used as a MySQL lock name, for example because it is too long
+type KeyError struct {
+	// Key is the offending key
+	Key string
+	// MaxLength is the maximum lock name length (in characters) the locker allows, always MaxKeyLength. It is the
+	// limit of MySQL 5.7 and later, the server itself is not asked.
+	MaxLength int
+	// Hint suggests how to fix the key
+	Hint string
+	// Err is the error returned by the server, it is nil when the key was rejected before reaching the server
+	Err error
+}
+
+func (e *KeyError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("invalid lock key %q: %v, %s", e.Key, e.Err, e.Hint)
+	}
+	return fmt.Sprintf("invalid lock key %q, %s", e.Key, e.Hint)
+}
+
+// Unwrap returns the error returned by the server, if any
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// ErrHistoryNotConfigured is returned by history operations when the locker has no history table configured
+var ErrHistoryNotConfigured = errors.New("lock history table is not configured")
+
+// ErrNotEnoughHistory is returned when there is not enough lock history to make an estimation
+var ErrNotEnoughHistory = errors.New("not enough lock history")
+
+// ErrReservationNotConfigured is returned by reservation operations when the locker has no reservation table configured
+var ErrReservationNotConfigured = errors.New("lock reservation table is not configured")
+
+// ErrInvalidReservationWindow is returned when a reservation does not end after it starts
+var ErrInvalidReservationWindow = errors.New("reservation must end after it starts")
+
+// ErrInvalidInterval is returned when a polling interval is not positive
+var ErrInvalidInterval = errors.New("interval must be positive")
+
+// ErrInvalidConfig is returned by Reconfigure when the settings it would apply are invalid
+var ErrInvalidConfig = errors.New("invalid locker configuration")
+
+// ErrNotReconfigurable is returned by Reconfigure when given an option which only takes effect when the locker is
+// created
+var ErrNotReconfigurable = errors.New("option can not be changed at runtime")
+
+// ErrReserved is returned when the lock can not be obtained because another owner reserved the key for now
+var ErrReserved = errors.New("key is reserved by another owner")
+
+// ErrOpenTx is returned when a lock is obtained from the pool while the caller has an open transaction on the same
+// pool and the OpenTxDeny policy is set
+var ErrOpenTx = errors.New("obtaining a lock from the pool while a transaction is open, use ObtainOnConn instead")
+
+// ErrLockerShutdown is returned when obtaining a lock through a locker which has been shut down
+var ErrLockerShutdown = errors.New("locker is shut down")
+
+// ErrObtainSkipped is returned when a middleware returned no error around an obtain without calling the next handler,
+// so the lock was not obtained
+var ErrObtainSkipped = errors.New("a middleware returned without obtaining the lock")
+
+// ErrReleaseTokenMismatch is returned by ForceRelease when the given token is not the release token of the lock
+var ErrReleaseTokenMismatch = errors.New("release token does not match the lock's")
+
+// ErrLockDegraded is returned by operations which need the lock's connection when they are called on a lock which
+// was not obtained because of WithFailOpen
+var ErrLockDegraded = errors.New("lock is degraded, it was not obtained")
+
+// ErrPromiseNotConfigured is returned by promise operations when the locker has no promise table configured
+var ErrPromiseNotConfigured = errors.New("promise table is not configured")
+
+// ErrPromiseAbandoned is returned when the producer of a promise lost its lock, or was stopped, before completing it
+var ErrPromiseAbandoned = errors.New("promise was abandoned by its producer")
+
+// KeyPolicyError is returned when obtaining a lock is rejected by a key policy
+type KeyPolicyError struct {
+	Key     string
+	Pattern string
+	Reason  string
+}
+
+func (e *KeyPolicyError) Error() string {
+	return fmt.Sprintf("key %q rejected by policy for %q: %s", e.Key, e.Pattern, e.Reason)
+}
+
+// ErrUnsupportedByServer is returned when the server lacks a function or feature needed by an operation, or when the
+// locker's user is not allowed to use it, so that callers can fall back gracefully
+type ErrUnsupportedByServer struct {
+	// Feature is the function or feature which is missing, for example "IS_USED_LOCK"
+	Feature string
+	// Err is the error returned by the server
+	Err error
+}
+
+func (e *ErrUnsupportedByServer) Error() string {
+	return fmt.Sprintf("%s is not supported by the server: %v", e.Feature, e.Err)
+}
+
+// Unwrap returns the error returned by the server
+func (e *ErrUnsupportedByServer) Unwrap() error {
+	return e.Err
+}
+
+// GuardError is returned when obtaining a lock is rejected by an acquisition guard, see WithAcquireGuard
+type GuardError struct {
+	Key string
+	// Err is the error returned by the guard
+	Err error
+}
+
+func (e *GuardError) Error() string {
+	return fmt.Sprintf("obtaining lock on %q rejected by guard: %v", e.Key, e.Err)
+}
+
+// Unwrap returns the error returned by the guard
+func (e *GuardError) Unwrap() error {
+	return e.Err
+}
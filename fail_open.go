@@ -0,0 +1,85 @@
+package gomysqllock
+
+import (
+	"context"
+	"errors"
+	"path"
+	"time"
+)
+
+// WithFailOpen is an emergency switch trading exclusivity for availability, for example during a database incident:
+// when obtaining a lock on a key matching one of the patterns (path.Match syntax) fails, a degraded lock is returned
+// instead of the error, so that callers proceed without holding the lock. The hook is called with the key and the
+// error every time, so that each such decision is loud. Only failures to reach or use the database fail open: keys
+// rejected by the locker's own rules (invalid keys, key policies, guards, reservations, shutdown), keys held by
+// someone else (ErrMySQLTimeout) and callers giving up (ErrGetLockContextCancelled, a done context) never do.
+// A degraded lock reports Degraded, is never healthy and releasing it only cancels its context. It can be toggled on
+// and off at runtime with Reconfigure, WithFailOpen(nil, nil) turns it off.
+func WithFailOpen(patterns []string, hook func(key string, err error)) lockerOpt {
+	return func(l *MysqlLocker) {
+		l.failOpenPatterns = patterns
+		l.failOpenHook = hook
+	}
+}
+
+// Degraded reports whether the lock was not actually obtained, but handed out because of WithFailOpen
+func (l *Lock) Degraded() bool {
+	return l.degraded
+}
+
+// failOpen returns a degraded lock on the key if obtaining it with ctx failed with err because of the database, and
+// the key is configured to fail open, nil otherwise
+func (l MysqlLocker) failOpen(ctx context.Context, key string, err error) *Lock {
+	config := l.config()
+	if len(config.failOpenPatterns) == 0 || !isOutage(ctx, err) || l.isCanary(key) {
+		return nil
+	}
+
+	matched := false
+	for _, pattern := range config.failOpenPatterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return nil
+	}
+
+	if config.failOpenHook != nil {
+		config.failOpenHook(key, err)
+	}
+
+	lockContext, cancelFunc := context.WithCancel(context.WithValue(context.Background(), lockKeyContextKey{}, key))
+	return &Lock{
+		key:             key,
+		name:            l.lockName(key),
+		unlocker:        make(chan struct{}, 1),
+		lostLockContext: lockContext,
+		cancelFunc:      cancelFunc,
+		locker:          &l,
+		obtainedAt:      time.Now(),
+		degraded:        true,
+		unhealthy:       1,
+	}
+}
+
+// isOutage reports whether err, returned when obtaining a lock with ctx, is a failure to reach or use the database,
+// rather than the locker refusing the lock, the key being held or the caller giving up
+func isOutage(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return !isRejection(err) && !errors.Is(err, ErrMySQLTimeout) && !errors.Is(err, ErrGetLockContextCancelled)
+}
+
+// isRejection reports whether err is the locker refusing the lock because of its own rules, rather than a failure to
+// obtain it
+func isRejection(err error) bool {
+	var keyErr *KeyError
+	var policyErr *KeyPolicyError
+	var guardErr *GuardError
+	return errors.As(err, &keyErr) || errors.As(err, &policyErr) || errors.As(err, &guardErr) ||
+		errors.Is(err, ErrReserved) || errors.Is(err, ErrLockerShutdown) || errors.Is(err, ErrOpenTx) ||
+		errors.Is(err, ErrMetadataNotConfigured) || errors.Is(err, ErrObtainSkipped)
+}
@@ -0,0 +1,99 @@
+package gomysqllock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Federation gives a single view over several lockers, typically one per primary a service talks to. Each locker is
+// a named domain.
+type Federation struct {
+	domains map[string]*MysqlLocker
+}
+
+// FederatedLockChange is a lock change seen in one of a federation's domains
+type FederatedLockChange struct {
+	LockChange
+	Domain string
+}
+
+// NewFederation returns a federation of the given lockers, keyed by domain name
+func NewFederation(domains map[string]*MysqlLocker) *Federation {
+	return &Federation{domains: domains}
+}
+
+// HeldLocks returns the locks currently held through each domain's locker, by domain name
+func (f *Federation) HeldLocks() map[string][]*Lock {
+	held := make(map[string][]*Lock, len(f.domains))
+	for name, locker := range f.domains {
+		held[name] = locker.HeldLocks()
+	}
+	return held
+}
+
+// PoolStats returns the pool statistics of each domain's locker, by domain name
+func (f *Federation) PoolStats() map[string]PoolStats {
+	stats := make(map[string]PoolStats, len(f.domains))
+	for name, locker := range f.domains {
+		stats[name] = locker.PoolStats()
+	}
+	return stats
+}
+
+// Watch watches every domain whose locker records history, see MysqlLocker.Watch, and merges their lock changes on
+// the returned channel. Domains without a history table are left out. The channel is closed once ctx is done or every
+// watched locker is shut down.
+func (f *Federation) Watch(ctx context.Context, interval time.Duration) (<-chan FederatedLockChange, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+	ctx, cancel := context.WithCancel(ctx)
+
+	sources := make(map[string]<-chan LockChange, len(f.domains))
+	for name, locker := range f.domains {
+		changes, err := locker.Watch(ctx, interval)
+		if errors.Is(err, ErrHistoryNotConfigured) {
+			continue
+		} else if err != nil {
+			cancel()
+			// drain the domains already watched so that their watchers exit
+			for _, changes := range sources {
+				for range changes {
+				}
+			}
+			return nil, fmt.Errorf("could not watch domain %q: %w", name, err)
+		}
+		sources[name] = changes
+	}
+
+	merged := make(chan FederatedLockChange)
+	var forwarders sync.WaitGroup
+	for name, changes := range sources {
+		name, changes, locker := name, changes, f.domains[name]
+		forwarders.Add(1)
+		started := locker.state.goroutine(func() {
+			defer forwarders.Done()
+			for change := range changes {
+				select {
+				case merged <- FederatedLockChange{LockChange: change, Domain: name}:
+				case <-ctx.Done():
+				}
+			}
+		})
+		if !started {
+			// the locker was shut down, its watcher exits without forwarding
+			forwarders.Done()
+		}
+	}
+
+	go func() {
+		forwarders.Wait()
+		cancel()
+		close(merged)
+	}()
+
+	return merged, nil
+}
@@ -0,0 +1,72 @@
+package gomysqllock
+
+import (
+	"context"
+	"sync"
+)
+
+// LockGroup ties several locks together, for work which must stop entirely as soon as any of its locks is gone
+type LockGroup struct {
+	name       string
+	ctx        context.Context
+	cancelFunc context.CancelFunc
+
+	mu    sync.Mutex
+	locks []*Lock
+}
+
+// NewLockGroup returns an empty lock group with the given name
+func NewLockGroup(name string) *LockGroup {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	return &LockGroup{
+		name:       name,
+		ctx:        ctx,
+		cancelFunc: cancelFunc,
+	}
+}
+
+// Name returns the name of the group
+func (g *LockGroup) Name() string {
+	return g.name
+}
+
+// Add adds locks to the group. Adding a lock which is already lost or released cancels the group's context right away.
+func (g *LockGroup) Add(locks ...*Lock) {
+	g.mu.Lock()
+	g.locks = append(g.locks, locks...)
+	g.mu.Unlock()
+
+	for _, lock := range locks {
+		go func(lock *Lock) {
+			select {
+			case <-lock.GetContext().Done():
+				g.cancelFunc()
+			case <-g.ctx.Done():
+			}
+		}(lock)
+	}
+}
+
+// Context returns a context which is cancelled as soon as any lock of the group is lost or released, or when the group
+// is released
+func (g *LockGroup) Context() context.Context {
+	return g.ctx
+}
+
+// Release releases all locks of the group and cancels its context. It returns the first error encountered, after
+// trying to release every lock.
+func (g *LockGroup) Release() error {
+	g.cancelFunc()
+
+	g.mu.Lock()
+	locks := g.locks
+	g.mu.Unlock()
+
+	var firstErr error
+	for _, lock := range locks {
+		if err := lock.Release(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,20 @@
+package gomysqllock
+
+import "context"
+
+// WithAcquireGuard adds a precondition evaluated before every GET_LOCK, for example checking a feature flag or local
+// state. When the guard returns an error, the lock is not requested and obtaining it fails with a GuardError wrapping
+// that error. Guards run in the order they are given, on the goroutine obtaining the lock and with its context.
+func WithAcquireGuard(guard func(ctx context.Context, key string) error) lockerOpt {
+	return func(l *MysqlLocker) { l.acquireGuards = append(l.acquireGuards, guard) }
+}
+
+// checkGuards returns a GuardError for the first guard rejecting the key
+func (l MysqlLocker) checkGuards(ctx context.Context, key string) error {
+	for _, guard := range l.acquireGuards {
+		if err := guard(ctx, key); err != nil {
+			return &GuardError{Key: key, Err: err}
+		}
+	}
+	return nil
+}
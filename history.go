@@ -0,0 +1,134 @@
+package gomysqllock
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// historySampleSize is the number of most recent holds of a key used for estimations
+const historySampleSize = 100
+
+// recordEndedTimeout bounds recording the end of a hold, which runs after the release whatever its context
+const recordEndedTimeout = 5 * time.Second
+
+// Outcomes recorded in the history table when a hold ends
+const (
+	OutcomeReleased = "released"
+	OutcomeLost     = "lost"
+	// OutcomeForced is recorded when the lock was released by ForceRelease, from outside the holding process
+	OutcomeForced = "forced"
+)
+
+// WithHistoryTable enables lock history: every obtained lock is recorded in the given table along with when and how
+// it ended. The table name is used verbatim in queries and must come from trusted configuration. The table can be
+// created with CreateHistoryTable. Recording history is best effort, locking never fails because of it.
+func WithHistoryTable(table string) lockerOpt {
+	return func(l *MysqlLocker) { l.historyTable = table }
+}
+
+// CreateHistoryTable creates the history table configured with WithHistoryTable, if it does not exist yet
+func (l MysqlLocker) CreateHistoryTable(ctx context.Context) error {
+	if l.historyTable == "" {
+		return ErrHistoryNotConfigured
+	}
+
+	_, err := l.db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+		lock_key VARCHAR(64) NOT NULL,
+		connection_id BIGINT UNSIGNED NOT NULL,
+		owner VARCHAR(255) NOT NULL,
+		obtained_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+		released_at TIMESTAMP(6) NULL,
+		outcome VARCHAR(16) NULL,
+		KEY lock_key_id (lock_key, id),
+		KEY released_at_id (released_at, id)
+	)`, l.historyTable))
+	if err != nil {
+		return fmt.Errorf("failed to create history table: %w", err)
+	}
+	return nil
+}
+
+// EstimateWait estimates how long obtaining the lock on key would block, based on how long the key was held recently
+// and how many sessions are already waiting for it. It returns 0 when the key is not locked, and
+// ErrNotEnoughHistory when the key has no completed holds in the history table.
+// Waiters are counted from performance_schema.metadata_locks; when it is not available they are not accounted for.
+func (l MysqlLocker) EstimateWait(ctx context.Context, key string) (time.Duration, error) {
+	if l.historyTable == "" {
+		return 0, ErrHistoryNotConfigured
+	}
+
+	if err := l.validateKey(key); err != nil {
+		return 0, err
+	}
+	name := l.lockName(key)
+
+	holder, err := l.holderConnectionID(ctx, key)
+	if err != nil || holder == 0 {
+		return 0, err
+	}
+
+	var averageHold sql.NullFloat64
+	err = l.readDB().QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT AVG(TIMESTAMPDIFF(MICROSECOND, obtained_at, released_at)) FROM "+
+			"(SELECT obtained_at, released_at FROM %s WHERE lock_key = ? AND released_at IS NOT NULL "+
+			"ORDER BY id DESC LIMIT %d) recent", l.historyTable, historySampleSize), name).Scan(&averageHold)
+	if err != nil {
+		return 0, fmt.Errorf("could not read lock history: %w", err)
+	} else if !averageHold.Valid {
+		return 0, ErrNotEnoughHistory
+	}
+	average := time.Duration(averageHold.Float64) * time.Microsecond
+
+	// how long the current holder has been holding the lock, if it recorded its hold
+	var heldMicros int64
+	err = l.readDB().QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT TIMESTAMPDIFF(MICROSECOND, obtained_at, CURRENT_TIMESTAMP(6)) FROM %s "+
+			"WHERE lock_key = ? AND connection_id = ? AND released_at IS NULL ORDER BY id DESC LIMIT 1",
+		l.historyTable), name, holder).Scan(&heldMicros)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("could not read lock history: %w", err)
+	}
+
+	var waiters int64
+	l.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM performance_schema.metadata_locks "+
+		"WHERE OBJECT_TYPE = 'USER LEVEL LOCK' AND OBJECT_NAME = ? AND LOCK_STATUS = 'PENDING'", name).Scan(&waiters)
+
+	remaining := average - time.Duration(heldMicros)*time.Microsecond
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining + time.Duration(waiters)*average, nil
+}
+
+// recordObtained records the start of the lock's hold
+func (l *Lock) recordObtained(ctx context.Context) {
+	if l.locker.historyTable == "" || l.locker.isCanary(l.key) {
+		return
+	}
+
+	res, err := l.bookkeeping().ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (lock_key, connection_id, owner) VALUES (?, ?, ?)", l.locker.historyTable),
+		l.name, l.connectionID, l.locker.owner)
+	if err != nil {
+		return
+	}
+	l.historyID, _ = res.LastInsertId()
+}
+
+// recordEnded records the end of the lock's hold. It goes through the pool, as the lock's connection may be broken, and
+// with its own context, as the release's may be done by then (see Lock.ReleaseDetached).
+func (l *Lock) recordEnded(outcome string) {
+	if l.locker.historyTable == "" || l.historyID == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), recordEndedTimeout)
+	defer cancel()
+	l.locker.db.ExecContext(ctx, fmt.Sprintf(
+		"UPDATE %s SET released_at = CURRENT_TIMESTAMP(6), outcome = ? WHERE id = ? AND released_at IS NULL",
+		l.locker.historyTable), outcome, l.historyID)
+}
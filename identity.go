@@ -0,0 +1,77 @@
+package gomysqllock
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// processStartTime approximates the start time of the process by the initialization time of this package
+var processStartTime = time.Now()
+
+var (
+	processIdentity     Identity
+	processIdentityOnce sync.Once
+)
+
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// Identity describes the process holding locks. Its string form is what the locker uses as owner in metadata, history
+// and reservations unless overridden with WithOwner.
+type Identity struct {
+	Hostname string
+	// PodName is read from the POD_NAME environment variable, which is commonly set through the Kubernetes downward API
+	PodName string
+	// ContainerID is read from the process' cgroups, it is empty outside of containers
+	ContainerID string
+	PID         int
+	StartTime   time.Time
+}
+
+// String formats the identity as "hostname[/pod][/container]:pid:start", with the container id shortened to at most
+// 12 characters and the start time in unix seconds
+func (i Identity) String() string {
+	parts := []string{i.Hostname}
+	if i.PodName != "" && i.PodName != i.Hostname {
+		parts = append(parts, i.PodName)
+	}
+	if id := i.ContainerID; id != "" {
+		if len(id) > 12 {
+			id = id[:12]
+		}
+		parts = append(parts, id)
+	}
+	return fmt.Sprintf("%s:%d:%d", strings.Join(parts, "/"), i.PID, i.StartTime.Unix())
+}
+
+// ProcessIdentity returns the identity of the current process. It is only computed once, so it stays stable for the
+// lifetime of the process.
+func ProcessIdentity() Identity {
+	processIdentityOnce.Do(func() {
+		hostname, _ := os.Hostname()
+		cgroup, _ := ioutil.ReadFile("/proc/self/cgroup")
+
+		processIdentity = Identity{
+			Hostname:    hostname,
+			PodName:     os.Getenv("POD_NAME"),
+			ContainerID: containerIDFromCgroup(string(cgroup)),
+			PID:         os.Getpid(),
+			StartTime:   processStartTime,
+		}
+	})
+	return processIdentity
+}
+
+// containerIDFromCgroup finds the container id in the content of /proc/<pid>/cgroup
+func containerIDFromCgroup(cgroup string) string {
+	for _, line := range strings.Split(cgroup, "\n") {
+		if id := containerIDPattern.FindString(line); id != "" {
+			return id
+		}
+	}
+	return ""
+}
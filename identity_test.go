@@ -0,0 +1,36 @@
+package gomysqllock
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentity_String(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+
+	identity := Identity{Hostname: "host", PID: 42, StartTime: start}
+	assert.Equal(t, "host:42:1600000000", identity.String())
+
+	identity.PodName = "pod"
+	identity.ContainerID = strings.Repeat("ab", 32)
+	assert.Equal(t, "host/pod/abababababab:42:1600000000", identity.String())
+
+	identity.ContainerID = "abc"
+	assert.Equal(t, "host/pod/abc:42:1600000000", identity.String(), "short container ids shall be kept whole")
+}
+
+func TestProcessIdentity(t *testing.T) {
+	assert.Equal(t, ProcessIdentity(), ProcessIdentity())
+	assert.NotZero(t, ProcessIdentity().PID)
+}
+
+func TestContainerIDFromCgroup(t *testing.T) {
+	id := strings.Repeat("0123456789abcdef", 4)
+
+	assert.Equal(t, id, containerIDFromCgroup("12:memory:/docker/"+id+"\n11:cpu:/docker/"+id))
+	assert.Equal(t, id, containerIDFromCgroup("0::/system.slice/docker-"+id+".scope"))
+	assert.Empty(t, containerIDFromCgroup("0::/"))
+}
@@ -0,0 +1,55 @@
+package gomysqllock
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"unicode/utf8"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MaxKeyLength is the maximum length (in characters) of lock names enforced by MySQL
+const MaxKeyLength = 64
+
+// mysqlErrUserLockWrongName is the number of MySQL's ER_USER_LOCK_WRONG_NAME error
+const mysqlErrUserLockWrongName = 3057
+
+const keyRemediationHint = "keep keys within 64 characters by shortening their prefix or hashing long keys"
+
+// validateKey makes sure the key's lock name is valid, so that it is not rejected by the server later
+func (l MysqlLocker) validateKey(key string) error {
+	if key == "" || utf8.RuneCountInString(l.lockName(key)) > MaxKeyLength {
+		return &KeyError{Key: key, MaxLength: MaxKeyLength, Hint: keyRemediationHint}
+	}
+	return nil
+}
+
+// WithKeyObfuscation makes the locker use the hex encoded HMAC-SHA256 of keys as MySQL lock names, so that keys never
+// show up in the processlist, performance_schema or the locker's tables. Application facing APIs (Obtain, IsLocked,
+// the lock context...) keep taking and returning plain keys, but Watch and HistoryReport return lock names.
+// Lock names are 64 characters long, so keys of any length can be used.
+func WithKeyObfuscation(hmacKey []byte) lockerOpt {
+	return func(l *MysqlLocker) { l.obfuscationKey = hmacKey }
+}
+
+// lockName returns the MySQL lock name of a key
+func (l MysqlLocker) lockName(key string) string {
+	key += l.namespace
+	if l.obfuscationKey == nil {
+		return key
+	}
+	mac := hmac.New(sha256.New, l.obfuscationKey)
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// keyError returns a KeyError if err is the server's rejection of the key as lock name, nil otherwise
+func keyError(key string, err error) *KeyError {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrUserLockWrongName {
+		return &KeyError{Key: key, MaxLength: MaxKeyLength, Hint: keyRemediationHint, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package gomysqllock
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// KeyPolicy is a set of rules enforced when obtaining locks on keys matching a pattern, see WithKeyPolicy
+type KeyPolicy struct {
+	// Deny rejects every key matching the pattern
+	Deny bool
+	// RequirePrefix rejects matching keys which do not start with the prefix
+	RequirePrefix string
+	// TryLock makes every acquisition give up immediately if the lock is held, whatever timeout was asked for
+	TryLock bool
+	// MaxHold releases locks automatically once they have been held for that long
+	MaxHold time.Duration
+	// RequireReleaseToken issues a release token to every lock obtained on a matching key, which ForceRelease
+	// requires. Tokens are stored in the metadata table, see WithMetadataTable.
+	RequireReleaseToken bool
+}
+
+// keyPolicyRule is a KeyPolicy along with the pattern of keys it applies to
+type keyPolicyRule struct {
+	pattern string
+	policy  KeyPolicy
+}
+
+// WithKeyPolicy enforces the policy on keys matching the pattern, which uses the syntax of path.Match (for example
+// "billing:*"). Rules are evaluated in the order they are given, and only the first matching one applies.
+// Like regexp.MustCompile, it panics if the pattern is malformed, so that a broken policy fails the locker's
+// construction rather than every Obtain.
+func WithKeyPolicy(pattern string, policy KeyPolicy) lockerOpt {
+	if err := validatePattern(pattern); err != nil {
+		panic(fmt.Sprintf("gomysqllock: invalid key policy pattern %q: %v", pattern, err))
+	}
+	return func(l *MysqlLocker) {
+		l.keyPolicies = append(l.keyPolicies, keyPolicyRule{pattern: pattern, policy: policy})
+	}
+}
+
+// validatePattern returns path.ErrBadPattern if the pattern is malformed. path.Match only reports malformed parts of
+// patterns it reaches while matching, so the whole pattern is checked here, following the syntax path.Match parses.
+func validatePattern(pattern string) error {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			if i++; i == len(pattern) {
+				return path.ErrBadPattern
+			}
+		case '[':
+			if i++; i < len(pattern) && pattern[i] == '^' {
+				i++
+			}
+			// a class holds at least one character or range before its closing bracket
+			for ranges := 0; i == len(pattern) || pattern[i] != ']' || ranges == 0; ranges++ {
+				var err error
+				if i, err = classChar(pattern, i); err != nil {
+					return err
+				}
+				if pattern[i] == '-' {
+					if i, err = classChar(pattern, i+1); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// classChar returns the index following the possibly escaped character of a character class at i, which can not be
+// the end of the pattern as the class is not closed yet
+func classChar(pattern string, i int) (int, error) {
+	if i == len(pattern) || pattern[i] == '-' || pattern[i] == ']' {
+		return 0, path.ErrBadPattern
+	}
+	if pattern[i] == '\\' {
+		if i++; i == len(pattern) {
+			return 0, path.ErrBadPattern
+		}
+	}
+	_, n := utf8.DecodeRuneInString(pattern[i:])
+	if i += n; i == len(pattern) {
+		return 0, path.ErrBadPattern
+	}
+	return i, nil
+}
+
+// matchKeyPolicy returns the first rule matching the key, or nil if none does
+func (l MysqlLocker) matchKeyPolicy(key string) (*keyPolicyRule, error) {
+	for i, rule := range l.keyPolicies {
+		matched, err := path.Match(rule.pattern, key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key policy pattern %q: %w", rule.pattern, err)
+		}
+		if matched {
+			return &l.keyPolicies[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// check returns a KeyPolicyError if the rule rejects the key
+func (r *keyPolicyRule) check(key string) error {
+	if r.policy.Deny {
+		return &KeyPolicyError{Key: key, Pattern: r.pattern, Reason: "locking is denied"}
+	}
+	if !strings.HasPrefix(key, r.policy.RequirePrefix) {
+		return &KeyPolicyError{Key: key, Pattern: r.pattern,
+			Reason: fmt.Sprintf("key must start with %q", r.policy.RequirePrefix)}
+	}
+	return nil
+}
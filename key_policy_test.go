@@ -0,0 +1,24 @@
+package gomysqllock
+
+import (
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePattern(t *testing.T) {
+	for _, pattern := range []string{"", "billing:*", "a?c", `a\*`, "[abc]", "[^a-z]*", `[\]]`, "[é-ü]"} {
+		assert.NoError(t, validatePattern(pattern), pattern)
+		_, err := path.Match(pattern, "")
+		assert.NoError(t, err, pattern)
+	}
+	for _, pattern := range []string{`a\`, "[", "[]", "[a", "[a-", "[a-]", "[-a]", "x[a", "billing:[*"} {
+		assert.Equal(t, path.ErrBadPattern, validatePattern(pattern), pattern)
+	}
+}
+
+func TestWithKeyPolicy_BadPattern(t *testing.T) {
+	assert.Panics(t, func() { WithKeyPolicy("billing:[", KeyPolicy{Deny: true}) })
+	assert.NotPanics(t, func() { WithKeyPolicy("billing:*", KeyPolicy{Deny: true}) })
+}
@@ -0,0 +1,48 @@
+// +build latencyinjection
+
+package gomysqllock
+
+import (
+	"math/rand"
+	"time"
+)
+
+// LatencyDistribution returns the delay to inject for a single operation
+type LatencyDistribution func() time.Duration
+
+// ConstantLatency returns a distribution which always delays by d
+func ConstantLatency(d time.Duration) LatencyDistribution {
+	return func() time.Duration { return d }
+}
+
+// UniformLatency returns a distribution which delays by a random duration in [min, max)
+func UniformLatency(min, max time.Duration) LatencyDistribution {
+	return func() time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(rand.Int63n(int64(max-min)))
+	}
+}
+
+// NormalLatency returns a distribution which delays by a normally distributed duration, never less than zero
+func NormalLatency(mean, stddev time.Duration) LatencyDistribution {
+	return func() time.Duration {
+		d := time.Duration(rand.NormFloat64()*float64(stddev)) + mean
+		if d < 0 {
+			return 0
+		}
+		return d
+	}
+}
+
+// WithLatencyInjection delays each lock acquisition and each heartbeat by a duration drawn from the given
+// distributions, either of which may be nil. Heartbeat delays count against the refresh deadline, so a large enough
+// delay makes the lock get lost just like it would against a slow database.
+// This option is only available in builds with the latencyinjection tag and is meant for load tests.
+func WithLatencyInjection(acquire, refresh LatencyDistribution) lockerOpt {
+	return func(l *MysqlLocker) {
+		l.acquireLatency = acquire
+		l.refreshLatency = refresh
+	}
+}
@@ -0,0 +1,52 @@
+// +build latencyinjection,!oldmysql
+
+package gomysqllock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyDistributions(t *testing.T) {
+	assert.Equal(t, time.Second, ConstantLatency(time.Second)())
+
+	for i := 0; i < 100; i++ {
+		d := UniformLatency(time.Millisecond, time.Millisecond*5)()
+		assert.True(t, d >= time.Millisecond && d < time.Millisecond*5)
+		assert.True(t, NormalLatency(0, time.Second)() >= 0)
+	}
+}
+
+func TestMysqlLocker_LatencyInjection_ContextCancelled(t *testing.T) {
+	db := setupDB(t)
+	locker := NewMysqlLocker(db, WithLatencyInjection(ConstantLatency(time.Second*10), nil))
+
+	ctxShort, cancelFunc := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancelFunc()
+
+	_, err := locker.ObtainContext(ctxShort, "latency")
+	assert.Equal(t, ErrGetLockContextCancelled, err)
+}
+
+func TestLock_Healthy_SlowHeartbeat(t *testing.T) {
+	db := setupDB(t)
+	transitions := make(chan bool, 10)
+	locker := NewMysqlLocker(db,
+		WithRefreshInterval(time.Millisecond*200),
+		WithHealthThreshold(time.Millisecond*50),
+		WithHealthHook(func(_ *Lock, healthy bool) { transitions <- healthy }),
+		WithLatencyInjection(nil, ConstantLatency(time.Millisecond*100)),
+	)
+
+	lock, err := locker.Obtain("slow_heartbeat")
+	assert.NoError(t, err, "failed to obtain lock")
+	assert.True(t, lock.Healthy())
+
+	assert.False(t, <-transitions)
+	assert.False(t, lock.Healthy())
+
+	releaseLock(t, lock)
+}
@@ -3,41 +3,299 @@ package gomysqllock
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// Lock denotes an acquired lock and presents two methods, one for getting the context which is cancelled when the lock
-// is lost/released and other for Releasing the lock
+// Lock denotes an acquired lock. It presents methods for getting the context which is cancelled when the lock is
+// lost/released, for Releasing the lock and for inspecting it while it is held
 type Lock struct {
+	// these are accessed atomically, they are kept first for 64-bit alignment on 32-bit platforms
+	lastProgress  int64
+	firstProgress int64
+	keepAliveBase int64
+	heartbeats    int64
+	releasedAt    int64
+
 	key             string
+	name            string
 	conn            *sql.Conn
+	connectionID    int64
+	ownsConn        bool
 	unlocker        chan (struct{})
 	lostLockContext context.Context
 	cancelFunc      context.CancelFunc
+	locker          *MysqlLocker
+	holdDeadline    time.Time
+	obtainedAt      time.Time
+
+	releaseToken string
+	// degraded locks were not obtained, see WithFailOpen
+	degraded bool
+
+	historyID   int64
+	lost        int32
+	unhealthy   int32
+	releaseOnce sync.Once
+	releaseErr  error
 }
 
-// GetContext returns a context which is cancelled when the lock is lost or released
-func (l Lock) GetContext() context.Context {
+// Key returns the key the lock was obtained on
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// GetContext returns a context which is cancelled when the lock is lost or released. For locks with a maximum hold
+// duration, its deadline is when the lock will be released automatically.
+func (l *Lock) GetContext() context.Context {
 	return l.lostLockContext
 }
 
-// Release unlocks the lock
-func (l Lock) Release() error {
+// Release unlocks the lock and closes its connection, unless the lock was obtained on a caller's connection.
+// Calling it more than once is safe, subsequent calls return the result of the first one
+func (l *Lock) Release() error {
+	return l.releaseContext(context.Background())
+}
+
+// ReleaseDetached is Release bounded by DefaultReleaseTimeout, for deferred release paths which must not hang on an
+// unresponsive database, typically after the request they served was cancelled. Release never uses the caller's
+// context, so a cancelled one can not make it fail. The timeout covers the BeforeRelease hook too. When it is hit
+// before the lock is released, the lock's own connection is discarded rather than returned to the pool, which frees
+// the lock on the server; a lock obtained on the caller's connection stays held until that connection is closed.
+func (l *Lock) ReleaseDetached() error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultReleaseTimeout)
+	defer cancel()
+	return l.releaseContext(ctx)
+}
+
+// releaseContext releases the lock once, with ctx bounding the queries it runs
+func (l *Lock) releaseContext(ctx context.Context) error {
+	l.releaseOnce.Do(func() {
+		vetoErr := l.runBeforeRelease(ctx)
+		defer func() {
+			if l.releaseErr == nil {
+				l.releaseErr = vetoErr
+			}
+		}()
+
+		if len(l.locker.middlewares) == 0 {
+			l.releaseErr = l.release(ctx)
+			return
+		}
+
+		released := false
+		l.releaseErr = l.locker.runMiddlewares(ctx, OpRelease, l.key,
+			func(context.Context, LockOp, string) error {
+				released = true
+				return l.release(ctx)
+			})
+		if !released {
+			l.release(ctx)
+		}
+	})
+	return l.releaseErr
+}
+
+// release unlocks the lock and cleans up after it, it must run only once
+func (l *Lock) release(ctx context.Context) error {
+	if l.degraded {
+		l.cancelFunc()
+		return nil
+	}
+	atomic.StoreInt64(&l.releasedAt, time.Now().UnixNano())
+
 	l.unlocker <- struct{}{}
-	l.conn.ExecContext(context.Background(), "DO RELEASE_LOCK(?)", l.key)
-	return l.conn.Close()
+	l.deleteMetadata(ctx)
+	_, err := l.conn.ExecContext(ctx, releaseLockQuery(l.name))
+	if l.ownsConn {
+		if err != nil {
+			// the session may still hold the lock, it must not go back to the pool: discarding it frees the lock
+			l.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		} else {
+			err = l.conn.Close()
+		}
+		atomic.AddInt64(&l.locker.state.pinnedConns, -1)
+	}
+	l.locker.state.unregister(l)
+
+	outcome := OutcomeReleased
+	if atomic.LoadInt32(&l.lost) == 1 {
+		outcome = OutcomeLost
+	}
+	l.recordEnded(outcome)
+
+	if l.locker.pinStatsHook != nil && !l.locker.isCanary(l.key) {
+		l.locker.pinStatsHook(l, l.PinStats())
+	}
+	return err
+}
+
+// sqlRunner runs queries, it is implemented by *sql.DB and *sql.Conn
+type sqlRunner interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// bookkeeping returns where the lock's rows (history, metadata) are written: its own connection, or the pool for locks
+// obtained on a caller's connection, so that they do not join a transaction open on it
+func (l *Lock) bookkeeping() sqlRunner {
+	if l.ownsConn {
+		return l.conn
+	}
+	return l.locker.db
+}
+
+// Healthy reports whether the lock's last heartbeat was faster than the locker's health threshold. An unhealthy lock is
+// still held, but its connection is degrading and holders may want to pause risky operations. A lost or released lock
+// is never healthy.
+func (l *Lock) Healthy() bool {
+	select {
+	case <-l.lostLockContext.Done():
+		return false
+	default:
+	}
+	return atomic.LoadInt32(&l.unhealthy) == 0
+}
+
+func (l *Lock) setHealthy(healthy bool) {
+	var unhealthy int32
+	if !healthy {
+		unhealthy = 1
+	}
+	if atomic.SwapInt32(&l.unhealthy, unhealthy) != unhealthy && l.locker.healthHook != nil {
+		l.locker.healthHook(l, healthy)
+	}
+}
+
+// KeepAliveFor ties the lock to the given work: the lock is released as soon as workCtx is done, or when Progress has
+// not been called for the base duration. This bounds how long a stuck holder keeps the lock, while work which
+// demonstrably progresses keeps extending it.
+func (l *Lock) KeepAliveFor(workCtx context.Context, base time.Duration) {
+	l.Progress()
+	atomic.StoreInt64(&l.keepAliveBase, int64(base))
+
+	l.locker.state.goroutine(func() {
+		timer := time.NewTimer(base)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-timer.C:
+				idle := time.Since(time.Unix(0, atomic.LoadInt64(&l.lastProgress)))
+				if idle >= base {
+					l.Release()
+					return
+				}
+				timer.Reset(base - idle)
+			case <-workCtx.Done():
+				l.Release()
+				return
+			case <-l.lostLockContext.Done():
+				return
+			}
+		}
+	})
 }
 
-func (l Lock) refresher(duration time.Duration, cancelFunc context.CancelFunc) {
+// capHold releases the lock at its hold deadline, which the lock's context reports
+func (l *Lock) capHold() {
+	l.locker.state.goroutine(func() {
+		timer := time.NewTimer(time.Until(l.holdDeadline))
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			l.Release()
+		case <-l.lostLockContext.Done():
+		}
+	})
+}
+
+// Remaining returns how long the lock will be held before it is released automatically, because of a key policy's
+// MaxHold or of KeepAliveFor without progress, so that long running work can checkpoint before the cutoff. It reports
+// false when the lock is held until it is released or lost. A lost or released lock has no time remaining.
+func (l *Lock) Remaining() (time.Duration, bool) {
+	select {
+	case <-l.lostLockContext.Done():
+		return 0, true
+	default:
+	}
+
+	var remaining time.Duration
+	bounded := false
+	if !l.holdDeadline.IsZero() {
+		remaining, bounded = time.Until(l.holdDeadline), true
+	}
+	if base := time.Duration(atomic.LoadInt64(&l.keepAliveBase)); base > 0 {
+		idle := time.Since(time.Unix(0, atomic.LoadInt64(&l.lastProgress)))
+		if !bounded || base-idle < remaining {
+			remaining, bounded = base-idle, true
+		}
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, bounded
+}
+
+// holdContext is the context of a lock with a maximum hold duration, its deadline tells holders when the lock will be
+// released. The context is cancelled by the release itself, like any lock context.
+type holdContext struct {
+	context.Context
+	deadline time.Time
+}
+
+// Deadline returns when the lock will be released
+func (c *holdContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+// Progress reports that the work guarded by the lock is progressing, extending a lock kept alive with KeepAliveFor
+func (l *Lock) Progress() {
+	now := time.Now().UnixNano()
+	atomic.StoreInt64(&l.lastProgress, now)
+	atomic.CompareAndSwapInt64(&l.firstProgress, 0, now)
+}
+
+// BindToTx ties the lock to the given transaction: the lock is released as soon as the returned transaction is
+// committed or rolled back. The transaction should be started on a connection other than the lock's own.
+func (l *Lock) BindToTx(tx *sql.Tx) *BoundTx {
+	return &BoundTx{Tx: tx, lock: l}
+}
+
+func (l *Lock) refresher(cancelFunc context.CancelFunc) {
+	interval := l.locker.config().refreshInterval
+
 	for {
+		// settings are read before every heartbeat, so that Reconfigure applies to held locks
+		config := l.locker.config()
+		timeout := config.refreshInterval
+		if config.adaptive() {
+			// slow but alive connections must not be lost because of a shortened interval, pings get the longest one
+			interval = clampDuration(interval, config.adaptiveRefreshMin, config.adaptiveRefreshMax)
+			timeout = config.adaptiveRefreshMax
+		} else {
+			interval = config.refreshInterval
+		}
+
 		select {
-		case <-time.After(duration):
-			deadline := time.Now().Add(duration)
+		case <-time.After(interval):
+			deadline := time.Now().Add(timeout)
 			contextDeadline, deadlineCancelFunc := context.WithDeadline(context.Background(), deadline)
+			start := time.Now()
+
+			// injected latency counts against the ping deadline, just like a slow database would
+			if l.locker.refreshLatency != nil {
+				sleepContext(contextDeadline, l.locker.refreshLatency())
+			}
 
 			// try refresh, else cancel
-			err := l.conn.PingContext(contextDeadline)
+			err := l.heartbeat(contextDeadline)
 			if err != nil {
+				// marked lost before the context is cancelled, so that holders releasing on it record a loss
+				atomic.StoreInt32(&l.lost, 1)
 				cancelFunc()
 				deadlineCancelFunc()
 				// this will make sure connection is closed
@@ -45,9 +303,43 @@ func (l Lock) refresher(duration time.Duration, cancelFunc context.CancelFunc) {
 				return
 			}
 			deadlineCancelFunc() // to avoid context leak
+			atomic.AddInt64(&l.heartbeats, 1)
+
+			latency := time.Since(start)
+			l.setHealthy(latency <= config.healthThreshold)
+			if config.adaptive() {
+				interval = nextRefreshInterval(interval, latency, config.healthThreshold,
+					config.adaptiveRefreshMin, config.adaptiveRefreshMax)
+			}
 		case <-l.unlocker:
 			cancelFunc()
 			return
 		}
 	}
 }
+
+// heartbeat pings the lock's connection, through the locker's middlewares
+func (l *Lock) heartbeat(ctx context.Context) error {
+	if len(l.locker.middlewares) == 0 {
+		return l.conn.PingContext(ctx)
+	}
+	return l.locker.runMiddlewares(ctx, OpHeartbeat, l.key, func(ctx context.Context, _ LockOp, _ string) error {
+		return l.conn.PingContext(ctx)
+	})
+}
+
+// sleepContext blocks for the given duration or until the context is done, whichever happens first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -4,31 +4,83 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sync/atomic"
 	"time"
 )
 
 // DefaultRefreshInterval is the periodic duration with which a connection is refreshed/pinged
 const DefaultRefreshInterval = time.Second
 
+// deadlineTimeoutMargin is kept between a context's deadline and the MySQL timeout derived from it, for the round trip
+const deadlineTimeoutMargin = 100 * time.Millisecond
+
 type lockerOpt func(locker *MysqlLocker)
 
 // MysqlLocker is the client which provide APIs to obtain lock
 type MysqlLocker struct {
-	db              *sql.DB
-	refreshInterval time.Duration
+	db                    *sql.DB
+	refreshInterval       time.Duration
+	acquireLatency        func() time.Duration
+	refreshLatency        func() time.Duration
+	healthThreshold       time.Duration
+	healthHook            func(lock *Lock, healthy bool)
+	metadataTable         string
+	metadataCodec         MetadataCodec
+	historyTable          string
+	owner                 string
+	reservationTable      string
+	acquireSLO            *acquireSLO
+	openTxPolicy          OpenTxPolicy
+	openTxWarn            func(key string)
+	keyPolicies           []keyPolicyRule
+	obfuscationKey        []byte
+	readOnlyDB            *sql.DB
+	releaseConcurrency    int
+	adaptiveRefreshMin    time.Duration
+	adaptiveRefreshMax    time.Duration
+	topologyInterval      time.Duration
+	topologyHook          func(old, new ServerIdentity)
+	acquireGuards         []func(ctx context.Context, key string) error
+	middlewares           []LockMiddleware
+	namespace             string
+	promiseTable          string
+	failOpenPatterns      []string
+	failOpenHook          func(key string, err error)
+	pinStatsHook          func(lock *Lock, stats PinStats)
+	beforeRelease         func(ctx context.Context, lock *Lock) error
+	beforeReleaseMaxDelay time.Duration
+	canaryKey             string
+	canaryInterval        time.Duration
+	canaryHook            func(latency time.Duration, err error)
+	state                 *lockerState
 }
 
 // NewMysqlLocker returns an instance of locker which can be used to obtain locks
 func NewMysqlLocker(db *sql.DB, lockerOpts ...lockerOpt) *MysqlLocker {
 	locker := &MysqlLocker{
-		db:              db,
-		refreshInterval: DefaultRefreshInterval,
+		db:                 db,
+		refreshInterval:    DefaultRefreshInterval,
+		releaseConcurrency: DefaultReleaseConcurrency,
+		metadataCodec:      JSONCodec{},
+		owner:              ProcessIdentity().String(),
+		state:              newLockerState(),
 	}
 
 	for _, opt := range lockerOpts {
 		opt(locker)
 	}
 
+	locker.state.live = locker.stagedLiveConfig()
+
+	if locker.topologyHook != nil {
+		locker.watchTopology()
+	}
+	if locker.canaryHook != nil {
+		// the owner is only final once every option is applied
+		locker.canaryKey += canaryKeySuffix(locker.owner)
+		locker.runCanary()
+	}
+
 	return locker
 }
 
@@ -37,6 +89,18 @@ func WithRefreshInterval(d time.Duration) lockerOpt {
 	return func(l *MysqlLocker) { l.refreshInterval = d }
 }
 
+// WithHealthThreshold sets the heartbeat latency above which an obtained lock is reported as unhealthy. It defaults to
+// half of the refresh interval, as heartbeats slower than the refresh interval lose the lock.
+func WithHealthThreshold(d time.Duration) lockerOpt {
+	return func(l *MysqlLocker) { l.healthThreshold = d }
+}
+
+// WithHealthHook sets a function which is called from the refresher goroutine every time an obtained lock turns
+// unhealthy or healthy again
+func WithHealthHook(hook func(lock *Lock, healthy bool)) lockerOpt {
+	return func(l *MysqlLocker) { l.healthHook = hook }
+}
+
 // Obtain tries to acquire lock (with no MySQL timeout) with background context. This call is expected to block is lock is already held
 func (l MysqlLocker) Obtain(key string) (*Lock, error) {
 	return l.ObtainTimeoutContext(context.Background(), key, -1)
@@ -54,48 +118,254 @@ func (l MysqlLocker) ObtainContext(ctx context.Context, key string) (*Lock, erro
 
 // ObtainTimeoutContext tries to acquire lock and gives up when the given context is cancelled
 func (l MysqlLocker) ObtainTimeoutContext(ctx context.Context, key string, timeout int) (*Lock, error) {
-	cancellableContext, cancelFunc := context.WithCancel(context.Background())
+	if err := l.checkOpenTx(ctx, key); err != nil {
+		return nil, err
+	}
+	return l.obtain(ctx, nil, key, timeout)
+}
 
-	dbConn, err := l.db.Conn(ctx)
+// ObtainOnConn tries to acquire lock (with no MySQL timeout) on the given connection instead of one from the pool, and
+// gives up when the given context is cancelled. The connection stays open when the lock is released.
+// This is the way to lock while working in a transaction: start the transaction on the same connection, so that
+// locking does not take a second connection from a possibly exhausted pool. The locker's own bookkeeping (history,
+// metadata, reservation checks) goes through the pool instead, so that it neither joins nor commits that transaction.
+func (l MysqlLocker) ObtainOnConn(ctx context.Context, conn *sql.Conn, key string) (*Lock, error) {
+	return l.obtain(ctx, conn, key, -1)
+}
+
+// ObtainTimeoutOnConn tries to acquire lock on the given connection with a MySQL timeout, see ObtainOnConn
+func (l MysqlLocker) ObtainTimeoutOnConn(ctx context.Context, conn *sql.Conn, key string, timeout int) (*Lock, error) {
+	return l.obtain(ctx, conn, key, timeout)
+}
+
+// obtain acquires the lock on the given connection, or on a connection from the pool if conn is nil, and fails open
+// if the key is configured to
+func (l MysqlLocker) obtain(ctx context.Context, conn *sql.Conn, key string, timeout int) (*Lock, error) {
+	lock, err := l.obtainThroughMiddlewares(ctx, conn, key, timeout)
 	if err != nil {
-		cancelFunc()
-		return nil, fmt.Errorf("failed to get a db connection: %w", err)
+		if degraded := l.failOpen(ctx, key, err); degraded != nil {
+			return degraded, nil
+		}
+		return nil, err
+	}
+	return lock, nil
+}
+
+// obtainThroughMiddlewares acquires the lock through the locker's middlewares
+func (l MysqlLocker) obtainThroughMiddlewares(ctx context.Context, conn *sql.Conn, key string,
+	timeout int) (*Lock, error) {
+	if len(l.middlewares) == 0 {
+		return l.obtainLock(ctx, conn, key, timeout)
+	}
+
+	var lock *Lock
+	err := l.runMiddlewares(ctx, OpObtain, key, func(ctx context.Context, _ LockOp, key string) error {
+		// a middleware retrying next must not leave the lock of its previous call held
+		if lock != nil {
+			lock.Release()
+		}
+		var err error
+		lock, err = l.obtainLock(ctx, conn, key, timeout)
+		return err
+	})
+	if err != nil {
+		if lock != nil {
+			lock.Release()
+		}
+		return nil, err
+	}
+	if lock == nil {
+		return nil, ErrObtainSkipped
+	}
+	return lock, nil
+}
+
+// obtainLock acquires the lock on the given connection, or on a connection from the pool if conn is nil
+func (l MysqlLocker) obtainLock(ctx context.Context, conn *sql.Conn, key string, timeout int) (*Lock, error) {
+	if err := l.validateKey(key); err != nil {
+		return nil, err
+	}
+	if l.state.isShutdown() {
+		return nil, ErrLockerShutdown
+	}
+
+	rule, err := l.matchKeyPolicy(key)
+	if err != nil {
+		return nil, err
+	}
+	if rule != nil {
+		if err := rule.check(key); err != nil {
+			return nil, err
+		}
+		if rule.policy.TryLock {
+			timeout = 0
+		}
+		if rule.policy.RequireReleaseToken && l.metadataTable == "" {
+			return nil, ErrMetadataNotConfigured
+		}
+	}
+
+	start := time.Now()
+
+	if l.acquireLatency != nil {
+		if err := sleepContext(ctx, l.acquireLatency()); err != nil {
+			l.observeFailedWait(key, start)
+			return nil, ErrGetLockContextCancelled
+		}
+	}
+
+	if err := l.checkGuards(ctx, key); err != nil {
+		return nil, err
+	}
+
+	ownsConn := conn == nil
+	if ownsConn {
+		conn, err = l.db.Conn(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get a db connection: %w", err)
+		}
+	}
+
+	timeout, derived := deriveTimeout(ctx, timeout)
+
+	var maxHold time.Duration
+	if rule != nil {
+		maxHold = rule.policy.MaxHold
+	}
+	lock, err := l.getLock(ctx, conn, ownsConn, key, timeout, maxHold)
+	if err != nil {
+		if ownsConn {
+			conn.Close()
+		}
+		if derived && err == ErrMySQLTimeout {
+			// the server gave up on behalf of the context's deadline
+			err = ErrGetLockContextCancelled
+		}
+		if err == ErrMySQLTimeout || err == ErrGetLockContextCancelled {
+			l.observeFailedWait(key, start)
+		}
+		return nil, err
+	}
+
+	if rule != nil && rule.policy.RequireReleaseToken {
+		if err := lock.issueReleaseToken(ctx); err != nil {
+			lock.Release()
+			return nil, err
+		}
 	}
 
-	row := dbConn.QueryRowContext(ctx, "SELECT COALESCE(GET_LOCK(?, ?), 2)", key, timeout)
+	if l.acquireSLO != nil && !l.isCanary(key) {
+		l.acquireSLO.observe(time.Since(start))
+	}
+
+	return lock, nil
+}
+
+// observeFailedWait records a wait for the key's lock which started at start and ended without it, against the
+// acquire SLO
+func (l MysqlLocker) observeFailedWait(key string, start time.Time) {
+	if l.acquireSLO != nil && !l.isCanary(key) {
+		l.acquireSLO.observeFailure(time.Since(start))
+	}
+}
+
+// deriveTimeout replaces an infinite (negative) MySQL timeout with one ending shortly before the context's deadline, so
+// that blocked waits end on the server too. MySQL timeouts are whole seconds, deadlines closer than a second are left
+// to client side cancellation. It reports whether the timeout was derived.
+func deriveTimeout(ctx context.Context, timeout int) (int, bool) {
+	deadline, ok := ctx.Deadline()
+	if timeout >= 0 || !ok {
+		return timeout, false
+	}
+
+	seconds := int((time.Until(deadline) - deadlineTimeoutMargin) / time.Second)
+	if seconds < 1 {
+		return timeout, false
+	}
+	return seconds, true
+}
+
+// getLock runs GET_LOCK on the connection and starts refreshing the lock once it is obtained, along with releasing it
+// after maxHold if it is positive
+func (l MysqlLocker) getLock(ctx context.Context, conn *sql.Conn, ownsConn bool, key string, timeout int,
+	maxHold time.Duration) (*Lock, error) {
+	name := l.lockName(key)
+	row := conn.QueryRowContext(ctx, getLockQuery(name, timeout))
 
 	var res int
-	err = row.Scan(&res)
+	var connectionID int64
+	err := row.Scan(&res, &connectionID)
 	if err != nil {
 		// mysql error does not tell if it was due to context closing, checking it manually
 		select {
 		case <-ctx.Done():
-			cancelFunc()
 			return nil, ErrGetLockContextCancelled
 		default:
 			break
 		}
-		cancelFunc()
+		if keyErr := keyError(key, err); keyErr != nil {
+			return nil, keyErr
+		}
+		if unsupportedErr := unsupportedError("GET_LOCK", err); unsupportedErr != nil {
+			return nil, unsupportedErr
+		}
 		return nil, fmt.Errorf("could not read mysql response: %w", err)
 	} else if res == 2 {
 		// Internal MySQL error occurred, such as out-of-memory, thread killed or others (the doc is not clear)
 		// Note: some MySQL/MariaDB versions (like MariaDB 10.1) does not support -1 as timeout parameters
-		cancelFunc()
 		return nil, ErrMySQLInternalError
 	} else if res == 0 {
 		// MySQL Timeout
-		cancelFunc()
 		return nil, ErrMySQLTimeout
 	}
 
+	// reservations are checked once the lock is held, so that callers which waited into a reservation window are rejected
+	var bookkeeping sqlRunner = conn
+	if !ownsConn {
+		bookkeeping = l.db
+	}
+	if err := l.checkReservations(ctx, bookkeeping, name); err != nil {
+		conn.ExecContext(context.Background(), releaseLockQuery(name))
+		return nil, err
+	}
+
+	cancellableContext, cancelFunc := context.WithCancel(context.WithValue(context.Background(), lockKeyContextKey{}, key))
+
 	lock := &Lock{
 		key:             key,
-		conn:            dbConn,
+		name:            name,
+		conn:            conn,
+		connectionID:    connectionID,
+		ownsConn:        ownsConn,
 		unlocker:        make(chan struct{}, 1),
 		lostLockContext: cancellableContext,
 		cancelFunc:      cancelFunc,
+		locker:          &l,
+		obtainedAt:      time.Now(),
+	}
+	if maxHold > 0 {
+		// the lock is visible to other goroutines once registered, its context must be final by then
+		lock.holdDeadline = lock.obtainedAt.Add(maxHold)
+		lock.lostLockContext = &holdContext{Context: cancellableContext, deadline: lock.holdDeadline}
+	}
+	if !l.state.register(lock) {
+		conn.ExecContext(context.Background(), releaseLockQuery(name))
+		cancelFunc()
+		return nil, ErrLockerShutdown
+	}
+	if ownsConn {
+		atomic.AddInt64(&l.state.pinnedConns, 1)
+	}
+	lock.recordObtained(ctx)
+	if !l.state.goroutine(func() { lock.refresher(cancelFunc) }) {
+		// the locker was shut down since the lock was registered, Shutdown may be releasing it already
+		cancelFunc()
+		lock.Release()
+		return nil, ErrLockerShutdown
+	}
+	if maxHold > 0 {
+		lock.capHold()
 	}
-	go lock.refresher(l.refreshInterval, cancelFunc)
 
 	return lock, nil
 }
@@ -106,29 +376,34 @@ func (l MysqlLocker) IsLocked(key string) (bool, error) {
 }
 
 func (l MysqlLocker) IsLockedContext(ctx context.Context, key string) (bool, error) {
-	_, cancelFunc := context.WithCancel(context.Background())
-
-	dbConn, err := l.db.Conn(ctx)
-	if err != nil {
-		cancelFunc()
-		return false, fmt.Errorf("failed to get a db connection: %w", err)
+	if err := l.validateKey(key); err != nil {
+		return false, err
 	}
 
-	row := dbConn.QueryRowContext(ctx, "SELECT COALESCE(IS_USED_LOCK(?), -1)", key)
-
 	var res int
-	err = row.Scan(&res)
+	row, err := l.queryRow(ctx, isUsedLockQuery, l.lockName(key))
+	if err == nil {
+		err = row.Scan(&res)
+	}
 	if err != nil {
-		// mysql error does not tell if it was due to context closing, checking it manually
-		select {
-		case <-ctx.Done():
-			cancelFunc()
-			return false, ErrGetLockContextCancelled
-		default:
-			break
-		}
-		cancelFunc()
-		return false, fmt.Errorf("could not read mysql response: %w", err)
+		return false, isLockedError(ctx, key, err)
 	}
 	return res != -1, nil
 }
+
+// isLockedError returns the error to report when checking whether key is locked failed with err
+func isLockedError(ctx context.Context, key string, err error) error {
+	// mysql error does not tell if it was due to context closing, checking it manually
+	select {
+	case <-ctx.Done():
+		return ErrGetLockContextCancelled
+	default:
+	}
+	if keyErr := keyError(key, err); keyErr != nil {
+		return keyErr
+	}
+	if unsupportedErr := unsupportedError("IS_USED_LOCK", err); unsupportedErr != nil {
+		return unsupportedErr
+	}
+	return fmt.Errorf("could not read mysql response: %w", err)
+}
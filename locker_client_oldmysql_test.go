@@ -3,6 +3,7 @@ package gomysqllock
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -108,5 +109,6 @@ func TestMysqlLocker_Obtain_oldDB_DBScanError(t *testing.T) {
 
 	// setting very long key name shall result into error
 	_, err := locker.Obtain(strings.Repeat("x", 100))
-	assert.Contains(t, err.Error(), "internal mysql error acquiring the lock")
+	var keyErr *KeyError
+	assert.True(t, errors.As(err, &keyErr))
 }
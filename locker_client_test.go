@@ -5,8 +5,12 @@ package gomysqllock
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -120,7 +124,9 @@ func TestMysqlLocker_Obtain_DBScanError(t *testing.T) {
 
 	// setting very long key name shall result into error
 	_, err := locker.Obtain(strings.Repeat("x", 100))
-	assert.Contains(t, err.Error(), "could not read mysql response")
+	var keyErr *KeyError
+	assert.True(t, errors.As(err, &keyErr))
+	assert.Equal(t, MaxKeyLength, keyErr.MaxLength)
 }
 
 func TestMysqlLocker_IsLocked(t *testing.T) {
@@ -136,4 +142,1147 @@ func TestMysqlLocker_IsLocked(t *testing.T) {
 	isLocked, err = locker.IsLocked(key)
 	assert.Equal(t, isLocked, false)
 	fmt.Println(isLocked, err)
-}
\ No newline at end of file
+}
+
+func TestLock_BindToTx(t *testing.T) {
+	db := setupDB(t)
+	locker := NewMysqlLocker(db)
+	key := "bound_tx"
+
+	lock := getLock(t, key, db)
+	tx, err := db.Begin()
+	assert.NoError(t, err, "failed to begin transaction")
+
+	boundTx := lock.BindToTx(tx)
+	assert.NoError(t, boundTx.Commit())
+
+	select {
+	case <-lock.GetContext().Done():
+	default:
+		assert.Fail(t, "lock's context is not cancelled after bound transaction is committed")
+	}
+
+	isLocked, err := locker.IsLocked(key)
+	assert.NoError(t, err)
+	assert.False(t, isLocked)
+
+	// releasing again is a no-op
+	assert.NoError(t, lock.Release())
+}
+
+func TestRoutedLocker(t *testing.T) {
+	dbs := []*sql.DB{setupDB(t), setupDB(t)}
+	locker := NewRoutedLocker(dbs, HashRoute(len(dbs)))
+	key := "routed"
+
+	lock, err := locker.Obtain(key)
+	assert.NoError(t, err, "failed to obtain lock")
+
+	isLocked, err := locker.IsLocked(key)
+	assert.NoError(t, err)
+	assert.True(t, isLocked)
+
+	releaseLock(t, lock)
+}
+
+func TestRoutedLocker_InvalidRoute(t *testing.T) {
+	locker := NewRoutedLocker([]*sql.DB{setupDB(t)}, func(string) int { return 1 })
+
+	_, err := locker.Obtain("routed")
+	assert.Equal(t, ErrInvalidRoute, err)
+}
+
+func TestLock_Healthy(t *testing.T) {
+	db := setupDB(t)
+	lock := getLock(t, "healthy", db)
+	assert.True(t, lock.Healthy())
+
+	releaseLock(t, lock)
+	assert.False(t, lock.Healthy())
+}
+
+func TestRunExclusive(t *testing.T) {
+	key := "run_exclusive"
+	locker := NewMysqlLocker(setupDB(t))
+
+	err := RunExclusive(context.Background(), "root@tcp(localhost:3306)/", key, func(ctx context.Context) error {
+		isLocked, err := locker.IsLocked(key)
+		assert.True(t, isLocked)
+		return err
+	})
+	assert.NoError(t, err)
+
+	isLocked, err := locker.IsLocked(key)
+	assert.NoError(t, err)
+	assert.False(t, isLocked)
+}
+
+const testMetadataTable = "gomysqllock_test.lock_metadata"
+
+func setupMetadataLocker(t *testing.T, db *sql.DB, lockerOpts ...lockerOpt) *MysqlLocker {
+	_, err := db.Exec("CREATE DATABASE IF NOT EXISTS gomysqllock_test")
+	assert.NoError(t, err, "failed to create test database")
+
+	locker := NewMysqlLocker(db, append([]lockerOpt{WithMetadataTable(testMetadataTable)}, lockerOpts...)...)
+	assert.NoError(t, locker.CreateMetadataTable(context.Background()), "failed to create metadata table")
+	return locker
+}
+
+func TestMysqlLocker_ObtainWithMetadata(t *testing.T) {
+	db := setupDB(t)
+	locker := setupMetadataLocker(t, db)
+	key := "metadata"
+
+	lock, err := locker.ObtainWithMetadata(context.Background(), key, map[string]string{"batch": "2024-06-01"})
+	assert.NoError(t, err, "failed to obtain lock")
+
+	var payload string
+	err = db.QueryRow("SELECT payload FROM "+testMetadataTable+" WHERE lock_key = ?", key).Scan(&payload)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"batch": "2024-06-01"}`, payload)
+
+	releaseLock(t, lock)
+
+	err = db.QueryRow("SELECT payload FROM "+testMetadataTable+" WHERE lock_key = ?", key).Scan(&payload)
+	assert.Equal(t, sql.ErrNoRows, err, "metadata is not deleted after lock is released")
+}
+
+func TestMysqlLocker_ObtainWithMetadata_NotConfigured(t *testing.T) {
+	locker := NewMysqlLocker(setupDB(t))
+
+	_, err := locker.ObtainWithMetadata(context.Background(), "metadata", "payload")
+	assert.Equal(t, ErrMetadataNotConfigured, err)
+}
+
+func TestMysqlLocker_HolderMetadata(t *testing.T) {
+	db := setupDB(t)
+	locker := setupMetadataLocker(t, db)
+	key := "holder_metadata"
+
+	type progress struct {
+		Done  int
+		Total int
+	}
+
+	lock, err := locker.ObtainWithMetadata(context.Background(), key, progress{Done: 1, Total: 10})
+	assert.NoError(t, err, "failed to obtain lock")
+
+	var p progress
+	assert.NoError(t, locker.HolderMetadata(context.Background(), key, &p))
+	assert.Equal(t, progress{Done: 1, Total: 10}, p)
+
+	assert.NoError(t, lock.SetMetadata(context.Background(), progress{Done: 5, Total: 10}))
+	assert.NoError(t, locker.HolderMetadata(context.Background(), key, &p))
+	assert.Equal(t, progress{Done: 5, Total: 10}, p)
+
+	releaseLock(t, lock)
+	assert.Equal(t, ErrNoHolderMetadata, locker.HolderMetadata(context.Background(), key, &p))
+}
+
+func TestMysqlLocker_PoolStats(t *testing.T) {
+	db := setupDB(t)
+	locker := NewMysqlLocker(db)
+
+	lock, err := locker.Obtain("pool_stats")
+	assert.NoError(t, err, "failed to obtain lock")
+
+	stats := locker.PoolStats()
+	assert.Equal(t, 1, stats.LockConnections)
+	assert.Equal(t, 1, stats.InUse)
+
+	releaseLock(t, lock)
+	assert.Equal(t, 0, locker.PoolStats().LockConnections)
+}
+
+func TestLock_ContextKey(t *testing.T) {
+	db := setupDB(t)
+	key := "context_key"
+	lock := getLock(t, key, db)
+
+	ctxKey, ok := KeyFromContext(lock.GetContext())
+	assert.True(t, ok)
+	assert.Equal(t, key, ctxKey)
+
+	_, ok = KeyFromContext(context.Background())
+	assert.False(t, ok)
+
+	releaseLock(t, lock)
+}
+
+const testHistoryTable = "gomysqllock_test.lock_history"
+
+func setupHistoryLocker(t *testing.T, db *sql.DB, lockerOpts ...lockerOpt) *MysqlLocker {
+	_, err := db.Exec("CREATE DATABASE IF NOT EXISTS gomysqllock_test")
+	assert.NoError(t, err, "failed to create test database")
+
+	locker := NewMysqlLocker(db, append([]lockerOpt{WithHistoryTable(testHistoryTable)}, lockerOpts...)...)
+	assert.NoError(t, locker.CreateHistoryTable(context.Background()), "failed to create history table")
+	return locker
+}
+
+func TestMysqlLocker_EstimateWait(t *testing.T) {
+	db := setupDB(t)
+	locker := setupHistoryLocker(t, db)
+	key := fmt.Sprintf("estimate_wait_%d", time.Now().UnixNano())
+
+	wait, err := locker.EstimateWait(context.Background(), key)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), wait, "a free key shall not need any wait")
+
+	// the key is held but has no completed holds yet
+	lock, err := locker.Obtain(key)
+	assert.NoError(t, err, "failed to obtain lock")
+	_, err = locker.EstimateWait(context.Background(), key)
+	assert.Equal(t, ErrNotEnoughHistory, err)
+
+	// past holds of 2 seconds each
+	for i := 0; i < 2; i++ {
+		_, err = db.Exec("INSERT INTO "+testHistoryTable+
+			" (lock_key, connection_id, owner, obtained_at, released_at, outcome) VALUES "+
+			"(?, 0, 'seed', CURRENT_TIMESTAMP(6) - INTERVAL 10 SECOND, CURRENT_TIMESTAMP(6) - INTERVAL 8 SECOND, ?)",
+			key, OutcomeReleased)
+		assert.NoError(t, err, "failed to seed history")
+	}
+
+	wait, err = locker.EstimateWait(context.Background(), key)
+	assert.NoError(t, err)
+	assert.True(t, wait > time.Millisecond*1500 && wait <= time.Second*2,
+		"the estimate shall be the average hold minus the current hold, got %v", wait)
+	releaseLock(t, lock)
+}
+
+func TestMysqlLocker_Reserve(t *testing.T) {
+	db := setupDB(t)
+	_, err := db.Exec("CREATE DATABASE IF NOT EXISTS gomysqllock_test")
+	assert.NoError(t, err, "failed to create test database")
+
+	table := WithReservationTable("gomysqllock_test.lock_reservations")
+	maintenance := NewMysqlLocker(db, table, WithOwner("maintenance"))
+	other := NewMysqlLocker(db, table, WithOwner("other"))
+	assert.NoError(t, maintenance.CreateReservationTable(context.Background()))
+	key := "reserved"
+
+	now := time.Now()
+	assert.Equal(t, ErrInvalidReservationWindow, maintenance.Reserve(context.Background(), key, now, now))
+	assert.NoError(t, maintenance.Reserve(context.Background(), key, now.Add(-time.Minute), now.Add(time.Minute)))
+
+	_, err = other.Obtain(key)
+	assert.Equal(t, ErrReserved, err)
+
+	lock, err := maintenance.Obtain(key)
+	assert.NoError(t, err, "reserving owner failed to obtain lock")
+	releaseLock(t, lock)
+
+	assert.NoError(t, maintenance.CancelReservations(context.Background(), key))
+	lock, err = other.Obtain(key)
+	assert.NoError(t, err, "failed to obtain lock after reservation is cancelled")
+	releaseLock(t, lock)
+}
+
+func TestMysqlLocker_OpenTxPolicy(t *testing.T) {
+	db := setupDB(t)
+	var warned []string
+	warnLocker := NewMysqlLocker(db, WithOpenTxPolicy(OpenTxWarn, func(key string) { warned = append(warned, key) }))
+	denyLocker := NewMysqlLocker(db, WithOpenTxPolicy(OpenTxDeny, nil))
+	key := "open_tx"
+
+	ctx, tx, err := denyLocker.BeginTx(context.Background(), nil)
+	assert.NoError(t, err, "failed to begin transaction")
+
+	_, err = denyLocker.ObtainContext(ctx, key)
+	assert.Equal(t, ErrOpenTx, err)
+
+	lock, err := warnLocker.ObtainContext(ctx, key)
+	assert.NoError(t, err, "failed to obtain lock")
+	assert.Equal(t, []string{key}, warned)
+	releaseLock(t, lock)
+
+	assert.NoError(t, tx.Rollback())
+	lock, err = denyLocker.ObtainContext(ctx, key)
+	assert.NoError(t, err, "failed to obtain lock after transaction ended")
+	releaseLock(t, lock)
+}
+
+func TestMysqlLocker_ObtainOnConn(t *testing.T) {
+	db := setupDB(t)
+	locker := NewMysqlLocker(db)
+	key := "on_conn"
+
+	conn, err := db.Conn(context.Background())
+	assert.NoError(t, err, "failed to get a db connection")
+	tx, err := conn.BeginTx(context.Background(), nil)
+	assert.NoError(t, err, "failed to begin transaction")
+
+	lock, err := locker.ObtainOnConn(context.Background(), conn, key)
+	assert.NoError(t, err, "failed to obtain lock")
+	assert.Equal(t, 0, locker.PoolStats().LockConnections)
+	releaseLock(t, lock)
+
+	// the connection and its transaction are still usable
+	assert.NoError(t, tx.Commit())
+	assert.NoError(t, conn.PingContext(context.Background()))
+	assert.NoError(t, conn.Close())
+}
+
+func TestMysqlLocker_ObtainOnConnBookkeeping(t *testing.T) {
+	db := setupDB(t)
+	locker := setupHistoryLocker(t, db)
+	key := fmt.Sprintf("on_conn_history_%d", time.Now().UnixNano())
+
+	conn, err := db.Conn(context.Background())
+	assert.NoError(t, err, "failed to get a db connection")
+	defer conn.Close()
+	tx, err := conn.BeginTx(context.Background(), nil)
+	assert.NoError(t, err, "failed to begin transaction")
+
+	lock, err := locker.ObtainOnConn(context.Background(), conn, key)
+	assert.NoError(t, err, "failed to obtain lock")
+	releaseLock(t, lock)
+	assert.NoError(t, tx.Rollback())
+
+	// the history is written outside of the caller's transaction, so its rollback keeps it
+	var outcome string
+	err = db.QueryRow("SELECT outcome FROM "+testHistoryTable+" WHERE lock_key = ?", key).Scan(&outcome)
+	assert.NoError(t, err, "history shall survive the rollback")
+	assert.Equal(t, OutcomeReleased, outcome)
+}
+
+func TestLock_KeepAliveFor(t *testing.T) {
+	db := setupDB(t)
+	lock := getLock(t, "keep_alive", db)
+
+	lock.KeepAliveFor(context.Background(), time.Millisecond*300)
+	for i := 0; i < 5; i++ {
+		time.Sleep(time.Millisecond * 100)
+		lock.Progress()
+	}
+	assert.NoError(t, lock.GetContext().Err(), "lock is released while work progresses")
+
+	// stop progressing
+	time.Sleep(time.Millisecond * 500)
+	assert.Error(t, lock.GetContext().Err(), "lock is not released after work stopped progressing")
+}
+
+func TestLock_KeepAliveFor_WorkDone(t *testing.T) {
+	db := setupDB(t)
+	lock := getLock(t, "keep_alive_done", db)
+
+	workCtx, cancelFunc := context.WithCancel(context.Background())
+	lock.KeepAliveFor(workCtx, time.Minute)
+	cancelFunc()
+
+	<-lock.GetContext().Done()
+	assert.NoError(t, lock.Release())
+}
+
+func TestLockGroup(t *testing.T) {
+	db := setupDB(t)
+	group := NewLockGroup("pipeline")
+	first := getLock(t, "group_first", db)
+	second := getLock(t, "group_second", db)
+	group.Add(first, second)
+
+	assert.NoError(t, group.Context().Err())
+
+	// simulate the loss of one member
+	second.conn.Close()
+	select {
+	case <-group.Context().Done():
+	case <-time.After(time.Second * 3):
+		assert.Fail(t, "group's context is not cancelled after a member is lost")
+	}
+
+	// releasing the lost member fails, as its connection is gone
+	group.Release()
+	assert.Error(t, first.GetContext().Err(), "group members are not released with the group")
+}
+
+func TestMysqlLocker_Watch(t *testing.T) {
+	db := setupDB(t)
+	locker := setupHistoryLocker(t, db)
+	key := fmt.Sprintf("watch_%d", time.Now().UnixNano())
+
+	_, err := locker.Watch(context.Background(), 0)
+	assert.Equal(t, ErrInvalidInterval, err, "non-positive intervals shall be rejected")
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	changes, err := locker.Watch(ctx, time.Millisecond*100)
+	assert.NoError(t, err)
+
+	lock, err := locker.Obtain(key)
+	assert.NoError(t, err, "failed to obtain lock")
+	releaseLock(t, lock)
+
+	var types []LockChangeType
+	for change := range changes {
+		if change.Key != key {
+			continue
+		}
+		assert.Equal(t, ProcessIdentity().String(), change.Owner)
+		types = append(types, change.Type)
+		if len(types) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []LockChangeType{LockObtained, LockReleased}, types)
+
+	cancelFunc()
+	for range changes {
+	}
+}
+
+func TestMysqlLocker_Shutdown(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	db := setupDB(t)
+	locker := setupHistoryLocker(t, db)
+
+	lock, err := locker.Obtain("shutdown")
+	assert.NoError(t, err, "failed to obtain lock")
+	lock.KeepAliveFor(context.Background(), time.Minute)
+	_, err = locker.Watch(context.Background(), time.Millisecond*100)
+	assert.NoError(t, err)
+
+	assert.NoError(t, locker.Shutdown(context.Background()))
+	assert.Error(t, lock.GetContext().Err(), "lock is not released on shutdown")
+
+	_, err = locker.Obtain("shutdown")
+	assert.Equal(t, ErrLockerShutdown, err)
+
+	assert.NoError(t, db.Close())
+}
+
+func TestDeriveTimeout(t *testing.T) {
+	timeout, derived := deriveTimeout(context.Background(), -1)
+	assert.Equal(t, -1, timeout)
+	assert.False(t, derived)
+
+	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancelFunc()
+
+	timeout, derived = deriveTimeout(ctx, -1)
+	assert.Equal(t, 4, timeout)
+	assert.True(t, derived)
+
+	timeout, derived = deriveTimeout(ctx, 10)
+	assert.Equal(t, 10, timeout, "explicit timeouts shall be kept")
+	assert.False(t, derived)
+
+	ctxShort, cancelShort := context.WithTimeout(context.Background(), time.Millisecond*500)
+	defer cancelShort()
+
+	timeout, derived = deriveTimeout(ctxShort, -1)
+	assert.Equal(t, -1, timeout)
+	assert.False(t, derived)
+}
+
+func TestMysqlLocker_LockContext_DerivedTimeout(t *testing.T) {
+	db := setupDB(t)
+	locker := NewMysqlLocker(db)
+	key := "derived_timeout"
+
+	lock := getLock(t, key, db)
+
+	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancelFunc()
+	_, err := locker.ObtainContext(ctx, key)
+	assert.Equal(t, ErrGetLockContextCancelled, err)
+
+	releaseLock(t, lock)
+}
+
+func TestMysqlLocker_KeyPolicy(t *testing.T) {
+	db := setupDB(t)
+	locker := NewMysqlLocker(db,
+		WithKeyPolicy("legacy:*", KeyPolicy{Deny: true}),
+		WithKeyPolicy("billing*", KeyPolicy{RequirePrefix: "billing:"}),
+		WithKeyPolicy("jobs:*", KeyPolicy{TryLock: true, MaxHold: time.Millisecond * 500}),
+	)
+
+	var policyErr *KeyPolicyError
+	_, err := locker.Obtain("legacy:import")
+	assert.True(t, errors.As(err, &policyErr))
+	assert.Equal(t, "legacy:*", policyErr.Pattern)
+
+	_, err = locker.Obtain("billing-run")
+	assert.True(t, errors.As(err, &policyErr))
+
+	// try-lock gives up right away
+	lock, err := locker.Obtain("jobs:report")
+	assert.NoError(t, err, "failed to obtain lock")
+	_, err = locker.Obtain("jobs:report")
+	assert.Equal(t, ErrMySQLTimeout, err)
+
+	// and the lock is released after its maximum hold duration
+	select {
+	case <-lock.GetContext().Done():
+	case <-time.After(time.Second * 2):
+		assert.Fail(t, "lock is not released after its maximum hold duration")
+	}
+}
+
+func TestMysqlLocker_AcquireGuard(t *testing.T) {
+	db := setupDB(t)
+	errDisabled := errors.New("disabled")
+	locker := NewMysqlLocker(db, WithAcquireGuard(func(ctx context.Context, key string) error {
+		if strings.HasPrefix(key, "disabled:") {
+			return errDisabled
+		}
+		return nil
+	}))
+
+	var guardErr *GuardError
+	_, err := locker.Obtain("disabled:job")
+	assert.True(t, errors.As(err, &guardErr))
+	assert.Equal(t, "disabled:job", guardErr.Key)
+	assert.True(t, errors.Is(err, errDisabled))
+
+	lock, err := locker.Obtain("enabled:job")
+	assert.NoError(t, err, "failed to obtain lock")
+	releaseLock(t, lock)
+}
+
+func TestMysqlLocker_KeyPolicy_BadPattern(t *testing.T) {
+	locker := NewMysqlLocker(setupDB(t), WithKeyPolicy("[", KeyPolicy{Deny: true}))
+
+	_, err := locker.Obtain("key")
+	assert.Contains(t, err.Error(), "invalid key policy pattern")
+}
+
+func TestMysqlLocker_HistoryReport(t *testing.T) {
+	db := setupDB(t)
+	locker := setupHistoryLocker(t, db)
+	key := fmt.Sprintf("report_%d", time.Now().UnixNano())
+
+	for i := 0; i < 3; i++ {
+		lock, err := locker.Obtain(key)
+		assert.NoError(t, err, "failed to obtain lock")
+		releaseLock(t, lock)
+	}
+
+	report, err := locker.HistoryReport(context.Background(), time.Now().Add(-time.Minute), time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+
+	var keyReport *KeyReport
+	for i := range report.Keys {
+		if report.Keys[i].Key == key {
+			keyReport = &report.Keys[i]
+		}
+	}
+	if assert.NotNil(t, keyReport, "key is missing from the report") {
+		assert.Equal(t, 3, keyReport.Holds)
+		assert.Equal(t, 1, keyReport.Owners)
+	}
+}
+
+func TestMysqlLocker_KeyObfuscation(t *testing.T) {
+	db := setupDB(t)
+	locker := NewMysqlLocker(db, WithKeyObfuscation([]byte("secret")))
+	key := "customer:" + strings.Repeat("x", 100)
+
+	name := locker.lockName(key)
+	assert.Len(t, name, MaxKeyLength)
+	assert.NotContains(t, name, "customer")
+
+	lock, err := locker.Obtain(key)
+	assert.NoError(t, err, "failed to obtain lock")
+
+	ctxKey, _ := KeyFromContext(lock.GetContext())
+	assert.Equal(t, key, ctxKey)
+
+	isLocked, err := locker.IsLocked(key)
+	assert.NoError(t, err)
+	assert.True(t, isLocked)
+
+	// the lock is only visible under its obfuscated name
+	isLocked, err = NewMysqlLocker(db).IsLocked(name)
+	assert.NoError(t, err)
+	assert.True(t, isLocked)
+
+	releaseLock(t, lock)
+}
+
+func TestMysqlLocker_ReadDB(t *testing.T) {
+	db := setupDB(t)
+	setupMetadataLocker(t, db)
+	setupHistoryLocker(t, db)
+	locker := NewMysqlLocker(db, WithMetadataTable(testMetadataTable), WithHistoryTable(testHistoryTable),
+		WithReadDB(setupDB(t)))
+	key := "read_db"
+
+	lock, err := locker.ObtainWithMetadata(context.Background(), key, "payload")
+	assert.NoError(t, err, "failed to obtain lock")
+
+	var payload string
+	assert.NoError(t, locker.HolderMetadata(context.Background(), key, &payload))
+	assert.Equal(t, "payload", payload)
+
+	report, err := locker.HistoryReport(context.Background(), time.Now().Add(-time.Minute), time.Now())
+	assert.NoError(t, err)
+	assert.True(t, report.FromReadDB)
+
+	releaseLock(t, lock)
+}
+
+func TestMysqlLocker_Release(t *testing.T) {
+	db := setupDB(t)
+	locker := NewMysqlLocker(db, WithReleaseConcurrency(2))
+
+	var locks []*Lock
+	for i := 0; i < 5; i++ {
+		lock, err := locker.Obtain(fmt.Sprintf("batch_release_%d", i))
+		assert.NoError(t, err, "failed to obtain lock")
+		locks = append(locks, lock)
+	}
+
+	results := locker.Release(context.Background(), locks...)
+	assert.Len(t, results, 5)
+	for key, err := range results {
+		assert.NoError(t, err, "failed to release %s", key)
+	}
+	assert.Equal(t, 0, locker.PoolStats().LockConnections)
+}
+
+func TestMysqlLocker_ObtainEphemeral(t *testing.T) {
+	db := setupDB(t)
+	locker := NewMysqlLocker(db)
+
+	first, err := locker.ObtainEphemeral(context.Background(), "upload:")
+	assert.NoError(t, err, "failed to obtain lock")
+	second, err := locker.ObtainEphemeral(context.Background(), "upload:")
+	assert.NoError(t, err, "failed to obtain lock")
+
+	assert.True(t, strings.HasPrefix(first.Key(), "upload:"))
+	assert.NotEqual(t, first.Key(), second.Key())
+
+	releaseLock(t, first)
+	releaseLock(t, second)
+}
+
+func TestMysqlLocker_TopologyHook(t *testing.T) {
+	db := setupDB(t)
+	locker := NewMysqlLocker(db, WithTopologyHook(time.Millisecond*50, func(old, new ServerIdentity) {
+		t.Errorf("unexpected topology change from %v to %v", old, new)
+	}))
+
+	identity, err := locker.CurrentServer(context.Background())
+	assert.NoError(t, err, "failed to read server identity")
+	assert.NotEmpty(t, identity.Hostname)
+
+	// the watcher keeps seeing the same server and stops on shutdown
+	time.Sleep(time.Millisecond * 200)
+	assert.NoError(t, locker.Shutdown(context.Background()))
+}
+
+func TestMysqlLocker_Middleware(t *testing.T) {
+	db := setupDB(t)
+
+	var mu sync.Mutex
+	var ops []LockOp
+	errQuota := errors.New("quota exceeded")
+	locker := NewMysqlLocker(db, WithRefreshInterval(time.Millisecond*100), WithMiddleware(
+		func(next LockHandler) LockHandler {
+			return func(ctx context.Context, op LockOp, key string) error {
+				mu.Lock()
+				ops = append(ops, op)
+				mu.Unlock()
+				return next(ctx, op, key)
+			}
+		},
+		func(next LockHandler) LockHandler {
+			return func(ctx context.Context, op LockOp, key string) error {
+				if op == OpObtain && strings.HasPrefix(key, "tenant_b:") {
+					return errQuota
+				}
+				return next(ctx, op, key)
+			}
+		},
+	))
+
+	_, err := locker.Obtain("tenant_b:job")
+	assert.Equal(t, errQuota, err)
+
+	lock, err := locker.Obtain("tenant_a:job")
+	assert.NoError(t, err, "failed to obtain lock")
+	time.Sleep(time.Millisecond * 250)
+	releaseLock(t, lock)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, OpObtain, ops[0])
+	assert.Equal(t, OpObtain, ops[1])
+	assert.Contains(t, ops, OpHeartbeat)
+	assert.Equal(t, OpRelease, ops[len(ops)-1])
+}
+
+func TestMysqlLocker_MiddlewareRetriesObtain(t *testing.T) {
+	db := setupDB(t)
+
+	var locker *MysqlLocker
+	var first *Lock
+	locker = NewMysqlLocker(db, WithMiddleware(func(next LockHandler) LockHandler {
+		return func(ctx context.Context, op LockOp, key string) error {
+			if op != OpObtain {
+				return next(ctx, op, key)
+			}
+			if err := next(ctx, op, key); err != nil {
+				return err
+			}
+			first = locker.HeldLocks()[0]
+			return next(ctx, op, key)
+		}
+	}))
+
+	lock, err := locker.ObtainTimeout("retried", 1)
+	assert.NoError(t, err, "the second call shall obtain the lock released by the first one")
+	assert.NotEqual(t, first, lock)
+	assert.Len(t, locker.HeldLocks(), 1)
+	assert.Error(t, first.GetContext().Err(), "the first call's lock shall be released")
+	releaseLock(t, lock)
+}
+
+func TestLock_Remaining(t *testing.T) {
+	db := setupDB(t)
+	locker := NewMysqlLocker(db, WithKeyPolicy("capped:*", KeyPolicy{MaxHold: time.Second * 10}))
+
+	lock, err := locker.Obtain("capped:job")
+	assert.NoError(t, err, "failed to obtain lock")
+	remaining, bounded := lock.Remaining()
+	assert.True(t, bounded)
+	assert.True(t, remaining > time.Second*9 && remaining <= time.Second*10)
+	deadline, ok := lock.GetContext().Deadline()
+	assert.True(t, ok, "capped lock shall report its release time as deadline")
+	assert.WithinDuration(t, time.Now().Add(time.Second*10), deadline, time.Second)
+	releaseLock(t, lock)
+
+	remaining, bounded = lock.Remaining()
+	assert.True(t, bounded)
+	assert.Equal(t, time.Duration(0), remaining)
+
+	lock, err = locker.Obtain("uncapped_job")
+	assert.NoError(t, err, "failed to obtain lock")
+	_, bounded = lock.Remaining()
+	assert.False(t, bounded)
+	_, ok = lock.GetContext().Deadline()
+	assert.False(t, ok)
+
+	workCtx, cancel := context.WithCancel(context.Background())
+	lock.KeepAliveFor(workCtx, time.Second*5)
+	remaining, bounded = lock.Remaining()
+	assert.True(t, bounded)
+	assert.True(t, remaining > time.Second*4 && remaining <= time.Second*5)
+	cancel()
+	<-lock.GetContext().Done()
+}
+
+func TestFederation(t *testing.T) {
+	db := setupDB(t)
+	orders := setupHistoryLocker(t, db)
+	billing := NewMysqlLocker(db)
+	federation := NewFederation(map[string]*MysqlLocker{"orders": orders, "billing": billing})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes, err := federation.Watch(ctx, time.Millisecond*100)
+	assert.NoError(t, err, "failed to watch federation")
+
+	key := fmt.Sprintf("federation_%d", time.Now().UnixNano())
+	ordersLock, err := orders.Obtain(key)
+	assert.NoError(t, err, "failed to obtain lock")
+	billingLock, err := billing.Obtain("federation_billing")
+	assert.NoError(t, err, "failed to obtain lock")
+
+	held := federation.HeldLocks()
+	assert.Equal(t, []*Lock{ordersLock}, held["orders"])
+	assert.Equal(t, []*Lock{billingLock}, held["billing"])
+	assert.Equal(t, 1, federation.PoolStats()["billing"].LockConnections)
+
+	select {
+	case change := <-changes:
+		assert.Equal(t, "orders", change.Domain)
+		assert.Equal(t, key, change.Key)
+		assert.Equal(t, LockObtained, change.Type)
+	case <-time.After(time.Second * 2):
+		assert.Fail(t, "no lock change seen")
+	}
+
+	releaseLock(t, ordersLock)
+	releaseLock(t, billingLock)
+	cancel()
+	for range changes {
+	}
+}
+
+func TestMysqlLocker_StmtReuse(t *testing.T) {
+	db := setupDB(t)
+	locker := NewMysqlLocker(db)
+
+	first, err := locker.stmt(context.Background(), isUsedLockQuery)
+	assert.NoError(t, err, "failed to prepare statement")
+	second, err := locker.stmt(context.Background(), isUsedLockQuery)
+	assert.NoError(t, err, "failed to prepare statement")
+	assert.Same(t, first, second)
+
+	for i := 0; i < 3; i++ {
+		isLocked, err := locker.IsLocked("stmt_reuse")
+		assert.NoError(t, err)
+		assert.False(t, isLocked)
+	}
+
+	assert.NoError(t, locker.Shutdown(context.Background()))
+	assert.Empty(t, locker.state.stmts)
+
+	isLocked, err := locker.IsLocked("stmt_reuse")
+	assert.NoError(t, err, "shut down lockers shall still answer without statements")
+	assert.False(t, isLocked)
+	assert.Empty(t, locker.state.stmts, "no statement shall be prepared after shutdown")
+}
+
+func TestMysqlLocker_TestNamespace(t *testing.T) {
+	db := setupDB(t)
+
+	var lock *Lock
+	t.Run("isolated", func(t *testing.T) {
+		first, cleanup := NewTestLocker(db)
+		defer cleanup()
+		second, cleanup := NewTestLocker(db)
+		defer cleanup()
+
+		var err error
+		lock, err = first.ObtainTimeout("shared_key", 0)
+		assert.NoError(t, err, "failed to obtain lock")
+		other, err := second.ObtainTimeout("shared_key", 0)
+		assert.NoError(t, err, "namespaced lockers shall not contend for the same key")
+		assert.Equal(t, "shared_key", other.Key())
+	})
+
+	// the namespaced lockers are shut down by their cleanup functions
+	select {
+	case <-lock.GetContext().Done():
+	default:
+		assert.Fail(t, "lock is not released at the end of the test")
+	}
+}
+
+func TestMysqlLocker_SupportBundle(t *testing.T) {
+	db := setupDB(t)
+	locker := setupHistoryLocker(t, db)
+
+	lock, err := locker.Obtain("support_bundle")
+	assert.NoError(t, err, "failed to obtain lock")
+	defer releaseLock(t, lock)
+
+	var buf strings.Builder
+	assert.NoError(t, locker.SupportBundle(context.Background(), &buf))
+
+	var bundle map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(buf.String()), &bundle), "support bundle is not valid JSON")
+	assert.NotEmpty(t, bundle["ServerVersion"])
+	assert.Equal(t, "ok", bundle["Capabilities"].(map[string]interface{})["IS_USED_LOCK"])
+	assert.Len(t, bundle["HeldLocks"], 1)
+	assert.NotEmpty(t, bundle["RecentEvents"])
+	assert.Equal(t, testHistoryTable, bundle["Config"].(map[string]interface{})["HistoryTable"])
+}
+
+func TestMysqlLocker_Reconfigure_HeldLocks(t *testing.T) {
+	db := setupDB(t)
+
+	var heartbeats int32
+	locker := NewMysqlLocker(db, WithRefreshInterval(time.Second*2), WithMiddleware(
+		func(next LockHandler) LockHandler {
+			return func(ctx context.Context, op LockOp, key string) error {
+				if op == OpHeartbeat {
+					atomic.AddInt32(&heartbeats, 1)
+				}
+				return next(ctx, op, key)
+			}
+		},
+	))
+
+	lock, err := locker.Obtain("reconfigure")
+	assert.NoError(t, err, "failed to obtain lock")
+
+	// the held lock picks the shorter interval up after its pending heartbeat
+	assert.NoError(t, locker.Reconfigure(WithRefreshInterval(time.Millisecond*100)))
+	time.Sleep(time.Millisecond * 2600)
+	assert.True(t, atomic.LoadInt32(&heartbeats) > 3, "held lock did not pick up the new refresh interval")
+
+	releaseLock(t, lock)
+}
+
+const testPromiseTable = "gomysqllock_test.promises"
+
+func TestPromise(t *testing.T) {
+	db := setupDB(t)
+	_, err := db.Exec("CREATE DATABASE IF NOT EXISTS gomysqllock_test")
+	assert.NoError(t, err, "failed to create test database")
+	locker := NewMysqlLocker(db, WithPromiseTable(testPromiseTable), WithRefreshInterval(time.Millisecond*100))
+	assert.NoError(t, locker.CreatePromiseTable(context.Background()), "failed to create promise table")
+	key := fmt.Sprintf("nightly_build_%d", time.Now().UnixNano())
+
+	producer := locker.Promise(key)
+	_, err = producer.Start(context.Background())
+	assert.NoError(t, err, "failed to start promise")
+
+	awaited := make(chan string)
+	go func() {
+		status, err := locker.Promise(key).Await(context.Background())
+		assert.NoError(t, err)
+		awaited <- status
+	}()
+
+	time.Sleep(time.Millisecond * 300)
+	assert.NoError(t, producer.Complete(context.Background(), "succeeded"))
+	assert.Equal(t, "succeeded", <-awaited)
+
+	// a completed run is returned right away
+	status, err := locker.Promise(key).Await(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "succeeded", status)
+
+	// a run whose producer lost its lock is abandoned
+	producer = locker.Promise(key)
+	_, err = producer.Start(context.Background())
+	assert.NoError(t, err, "failed to start promise")
+	producer.lock.Release()
+	_, err = locker.Promise(key).Await(context.Background())
+	assert.Equal(t, ErrPromiseAbandoned, err)
+	assert.Equal(t, ErrPromiseAbandoned, producer.Complete(context.Background(), "succeeded"))
+}
+
+func TestMysqlLocker_ForceRelease(t *testing.T) {
+	db := setupDB(t)
+	locker := setupMetadataLocker(t, db, WithRefreshInterval(time.Millisecond*100),
+		WithKeyPolicy("protected:*", KeyPolicy{RequireReleaseToken: true}))
+
+	lock, err := locker.Obtain("protected:job")
+	assert.NoError(t, err, "failed to obtain lock")
+	assert.NotEmpty(t, lock.ReleaseToken())
+
+	assert.Equal(t, ErrReleaseTokenMismatch, locker.ForceRelease(context.Background(), "protected:job", ""))
+	assert.Equal(t, ErrReleaseTokenMismatch, locker.ForceRelease(context.Background(), "protected:job", "wrong"))
+	assert.True(t, lock.Healthy(), "lock shall still be held")
+
+	assert.NoError(t, locker.ForceRelease(context.Background(), "protected:job", lock.ReleaseToken()))
+	select {
+	case <-lock.GetContext().Done():
+	case <-time.After(time.Second * 2):
+		assert.Fail(t, "lock is not lost after being force released")
+	}
+
+	// unprotected keys need no token
+	lock, err = locker.Obtain("unprotected_job")
+	assert.NoError(t, err, "failed to obtain lock")
+	assert.Empty(t, lock.ReleaseToken())
+	assert.NoError(t, locker.ForceRelease(context.Background(), "unprotected_job", ""))
+	<-lock.GetContext().Done()
+}
+
+func TestMysqlLocker_FailOpen(t *testing.T) {
+	// nothing listens on this port, so that the database is unreachable
+	broken, err := sql.Open("mysql", "root@tcp(localhost:1)/")
+	assert.NoError(t, err, "failed to setup db")
+	defer broken.Close()
+
+	var failedOpen []error
+	locker := NewMysqlLocker(broken, WithFailOpen([]string{"payments:*"}, func(key string, err error) {
+		failedOpen = append(failedOpen, err)
+	}))
+
+	lock, err := locker.ObtainTimeout("payments:settle", 0)
+	assert.NoError(t, err, "matching key shall fail open")
+	assert.True(t, lock.Degraded())
+	assert.False(t, lock.Healthy())
+	assert.Len(t, failedOpen, 1)
+	assert.NoError(t, lock.Release())
+	<-lock.GetContext().Done()
+
+	_, err = locker.ObtainTimeout("orders:settle", 0)
+	assert.Error(t, err, "other keys shall not fail open")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	lock, err = locker.ObtainContext(ctx, "payments:settle")
+	assert.Error(t, err, "callers giving up shall not fail open")
+	assert.Nil(t, lock)
+
+	// the switch is turned off at runtime
+	assert.NoError(t, locker.Reconfigure(WithFailOpen(nil, nil)))
+	_, err = locker.ObtainTimeout("payments:settle", 0)
+	assert.Error(t, err)
+	assert.Len(t, failedOpen, 1)
+}
+
+func TestMysqlLocker_FailOpenHeldKey(t *testing.T) {
+	db := setupDB(t)
+	holder := NewMysqlLocker(db)
+	held, err := holder.Obtain("payments:settle")
+	assert.NoError(t, err, "failed to obtain lock")
+	defer releaseLock(t, held)
+
+	locker := NewMysqlLocker(db, WithFailOpen([]string{"payments:*"}, func(key string, err error) {
+		t.Errorf("key held by another session shall not fail open: %v", err)
+	}))
+	_, err = locker.ObtainTimeout("payments:settle", 0)
+	assert.Equal(t, ErrMySQLTimeout, err)
+}
+
+func TestLock_PinStats(t *testing.T) {
+	db := setupDB(t)
+	reported := make(chan PinStats, 1)
+	locker := NewMysqlLocker(db, WithRefreshInterval(time.Millisecond*100),
+		WithPinStatsHook(func(lock *Lock, stats PinStats) { reported <- stats }))
+
+	lock, err := locker.Obtain("pin_stats")
+	assert.NoError(t, err, "failed to obtain lock")
+	time.Sleep(time.Millisecond * 300)
+	lock.Progress()
+	stats := lock.PinStats()
+	assert.True(t, stats.ReleasedAt.IsZero())
+	assert.True(t, stats.UntilFirstProgress >= time.Millisecond*300)
+	assert.True(t, stats.Heartbeats >= 1)
+
+	time.Sleep(time.Millisecond * 200)
+	releaseLock(t, lock)
+	stats = <-reported
+	assert.False(t, stats.ReleasedAt.IsZero())
+	assert.True(t, stats.Pinned >= time.Millisecond*500)
+	assert.True(t, stats.AfterLastProgress >= time.Millisecond*200)
+}
+
+func TestLock_BeforeRelease(t *testing.T) {
+	db := setupDB(t)
+	errNotDurable := errors.New("write not durable yet")
+	var calls int32
+	locker := NewMysqlLocker(db, WithBeforeRelease(func(ctx context.Context, lock *Lock) error {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return errNotDurable
+		}
+		return nil
+	}, time.Second))
+
+	// the release waits for the hook to let it go ahead
+	lock, err := locker.Obtain("before_release")
+	assert.NoError(t, err, "failed to obtain lock")
+	assert.NoError(t, lock.Release())
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// and happens anyway once the delay is exhausted
+	vetoing := NewMysqlLocker(db, WithBeforeRelease(func(ctx context.Context, lock *Lock) error {
+		return errNotDurable
+	}, time.Millisecond*200))
+	lock, err = vetoing.Obtain("before_release")
+	assert.NoError(t, err, "failed to obtain lock")
+	err = lock.Release()
+	assert.True(t, errors.Is(err, errNotDurable))
+	<-lock.GetContext().Done()
+	isLocked, err := locker.IsLocked("before_release")
+	assert.NoError(t, err)
+	assert.False(t, isLocked)
+}
+
+func TestMysqlLocker_IsLockedConsistent(t *testing.T) {
+	db := setupDB(t)
+	locker := NewMysqlLocker(db)
+	other := NewMysqlLocker(db)
+
+	lock, err := locker.Obtain("is_locked_consistent")
+	assert.NoError(t, err, "failed to obtain lock")
+
+	isLocked, err := locker.IsLockedConsistent(context.Background(), "is_locked_consistent")
+	assert.NoError(t, err)
+	assert.True(t, isLocked)
+	isLocked, err = other.IsLockedConsistent(context.Background(), "is_locked_consistent")
+	assert.NoError(t, err)
+	assert.True(t, isLocked, "locks held by other lockers shall be seen on the server")
+
+	releaseLock(t, lock)
+	isLocked, err = locker.IsLockedConsistent(context.Background(), "is_locked_consistent")
+	assert.NoError(t, err)
+	assert.False(t, isLocked)
+
+	// a lost lock is still registered until it is released, it must not be reported from the registry
+	lock, err = locker.Obtain("is_locked_consistent")
+	assert.NoError(t, err, "failed to obtain lock")
+	_, err = lock.conn.ExecContext(context.Background(), releaseLockQuery(lock.name))
+	assert.NoError(t, err)
+	lock.cancelFunc()
+	isLocked, err = locker.IsLockedConsistent(context.Background(), "is_locked_consistent")
+	assert.NoError(t, err)
+	assert.False(t, isLocked, "lost locks shall be checked on the server")
+	releaseLock(t, lock)
+}
+
+func TestLock_ReleaseDetached(t *testing.T) {
+	db := setupDB(t)
+	locker := NewMysqlLocker(db)
+
+	requestCtx, cancel := context.WithCancel(context.Background())
+	lock, err := locker.ObtainContext(requestCtx, "release_detached")
+	assert.NoError(t, err, "failed to obtain lock")
+
+	// the request is cancelled before its deferred release runs
+	cancel()
+	assert.NoError(t, lock.ReleaseDetached())
+
+	isLocked, err := locker.IsLocked("release_detached")
+	assert.NoError(t, err)
+	assert.False(t, isLocked)
+}
+
+func TestLock_ReleaseTimedOut(t *testing.T) {
+	db := setupDB(t)
+	db.SetMaxOpenConns(1)
+	locker := NewMysqlLocker(db, WithBeforeRelease(func(ctx context.Context, lock *Lock) error {
+		return errors.New("not durable yet")
+	}, time.Minute))
+
+	lock, err := locker.Obtain("release_timed_out")
+	assert.NoError(t, err, "failed to obtain lock")
+
+	// the hook vetoes until the release's budget is exhausted, leaving none for RELEASE_LOCK
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	start := time.Now()
+	assert.Error(t, lock.releaseContext(ctx))
+	assert.True(t, time.Since(start) < time.Second, "the hook shall be bounded by the release's context")
+
+	// the only pool connection is a new one, the lock's session was discarded along with the lock
+	assert.Eventually(t, func() bool {
+		isLocked, err := locker.IsLocked("release_timed_out")
+		return err == nil && !isLocked
+	}, time.Second*3, time.Millisecond*100)
+}
+
+func TestMysqlLocker_Canary(t *testing.T) {
+	db := setupDB(t)
+	probes := make(chan error, 10)
+	locker := NewMysqlLocker(db, WithCanary("canary_probe", time.Millisecond*100, func(latency time.Duration, err error) {
+		select {
+		case probes <- err:
+		default:
+		}
+	}))
+
+	assert.NoError(t, <-probes)
+
+	// a probe key held elsewhere makes probes fail
+	held, err := NewMysqlLocker(db).Obtain(locker.canaryKey)
+	assert.NoError(t, err, "failed to obtain lock")
+	timeout := time.After(time.Second * 5)
+failing:
+	for {
+		select {
+		case err := <-probes:
+			if err != nil {
+				assert.Equal(t, ErrMySQLTimeout, err)
+				break failing
+			}
+		case <-timeout:
+			assert.Fail(t, "probes did not fail while the probe key was held")
+			break failing
+		}
+	}
+	releaseLock(t, held)
+
+	assert.NoError(t, locker.Shutdown(context.Background()))
+}
@@ -0,0 +1,156 @@
+package gomysqllock
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// MetadataCodec encodes and decodes the metadata payloads attached to obtained locks
+type MetadataCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec is the default MetadataCodec, it encodes payloads as JSON
+type JSONCodec struct{}
+
+// Marshal encodes v as JSON
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes JSON data into v
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// WithMetadataTable enables lock metadata, which is stored in the given table. The table name is used verbatim in
+// queries and must come from trusted configuration. The table can be created with CreateMetadataTable.
+func WithMetadataTable(table string) lockerOpt {
+	return func(l *MysqlLocker) { l.metadataTable = table }
+}
+
+// WithMetadataCodec sets the codec used to encode metadata payloads, JSONCodec is used by default
+func WithMetadataCodec(codec MetadataCodec) lockerOpt {
+	return func(l *MysqlLocker) { l.metadataCodec = codec }
+}
+
+// CreateMetadataTable creates the metadata table configured with WithMetadataTable, if it does not exist yet.
+// Each row belongs to the MySQL connection (session) which held the lock when the row was written, so rows left
+// behind by lost locks are recognised as stale.
+func (l MysqlLocker) CreateMetadataTable(ctx context.Context) error {
+	if l.metadataTable == "" {
+		return ErrMetadataNotConfigured
+	}
+
+	_, err := l.db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		lock_key VARCHAR(64) NOT NULL PRIMARY KEY,
+		connection_id BIGINT UNSIGNED NOT NULL,
+		owner VARCHAR(255) NOT NULL,
+		payload BLOB,
+		release_token VARCHAR(64) NULL,
+		updated_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6)
+	)`, l.metadataTable))
+	if err != nil {
+		return fmt.Errorf("failed to create metadata table: %w", err)
+	}
+	return nil
+}
+
+// ObtainWithMetadata tries to acquire lock like ObtainContext, and attaches the given metadata to it once it is held.
+// Other nodes can read the metadata while the lock is held. If the metadata can not be written the lock is released
+// and an error is returned.
+func (l MysqlLocker) ObtainWithMetadata(ctx context.Context, key string, metadata interface{}) (*Lock, error) {
+	if l.metadataTable == "" {
+		return nil, ErrMetadataNotConfigured
+	}
+
+	lock, err := l.ObtainContext(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lock.SetMetadata(ctx, metadata); err != nil {
+		lock.Release()
+		return nil, err
+	}
+	return lock, nil
+}
+
+// SetMetadata replaces the metadata attached to the lock, for example to report progress to waiters
+func (l *Lock) SetMetadata(ctx context.Context, metadata interface{}) error {
+	if l.locker.metadataTable == "" {
+		return ErrMetadataNotConfigured
+	}
+	if l.degraded {
+		return ErrLockDegraded
+	}
+
+	payload, err := l.locker.metadataCodec.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	if l.releaseToken != "" {
+		err = l.writeMetadata(ctx, payload)
+	} else {
+		_, err = l.bookkeeping().ExecContext(ctx, fmt.Sprintf(
+			"INSERT INTO %s (lock_key, connection_id, owner, payload) VALUES (?, ?, ?, ?) "+
+				"ON DUPLICATE KEY UPDATE connection_id = VALUES(connection_id), owner = VALUES(owner), "+
+				"payload = VALUES(payload)",
+			l.locker.metadataTable), l.name, l.connectionID, l.locker.owner, payload)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	return nil
+}
+
+// HolderMetadata decodes the metadata attached by the current holder of the key into metadata, so that waiters can see
+// what the holder reports. ErrNoHolderMetadata is returned when the key is not locked or its holder attached nothing.
+// With WithReadDB, metadata written moments ago may not be visible yet.
+func (l MysqlLocker) HolderMetadata(ctx context.Context, key string, metadata interface{}) error {
+	if l.metadataTable == "" {
+		return ErrMetadataNotConfigured
+	}
+
+	if err := l.validateKey(key); err != nil {
+		return err
+	}
+	name := l.lockName(key)
+
+	holder, err := l.holderConnectionID(ctx, key)
+	if err != nil {
+		return err
+	} else if holder == 0 {
+		return ErrNoHolderMetadata
+	}
+
+	// rows written by connections which no longer hold the lock are stale and ignored
+	var payload []byte
+	err = l.readDB().QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT payload FROM %s WHERE lock_key = ? AND connection_id = ?", l.metadataTable),
+		name, holder).Scan(&payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNoHolderMetadata
+	} else if err != nil {
+		return fmt.Errorf("could not read holder metadata: %w", err)
+	}
+
+	if err := l.metadataCodec.Unmarshal(payload, metadata); err != nil {
+		return fmt.Errorf("failed to decode metadata: %w", err)
+	}
+	return nil
+}
+
+// deleteMetadata removes the lock's metadata row, if it is still the one written by this lock's connection
+func (l *Lock) deleteMetadata(ctx context.Context) {
+	if l.locker.metadataTable == "" || l.degraded {
+		return
+	}
+	l.bookkeeping().ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE lock_key = ? AND connection_id = ?",
+		l.locker.metadataTable), l.name, l.connectionID)
+}
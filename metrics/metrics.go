@@ -0,0 +1,129 @@
+// Package metrics is the catalog of metrics recommended for go-mysql-lock, with their names, types and labels, so that
+// exporters and dashboards-as-code tooling agree on them. The library itself reports through hooks and APIs, each
+// metric tells which one feeds it.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Namespace prefixes every metric name
+const Namespace = "gomysqllock"
+
+// Type is the kind of a metric, as understood by Prometheus
+type Type string
+
+// Metric types
+const (
+	Counter   Type = "counter"
+	Gauge     Type = "gauge"
+	Histogram Type = "histogram"
+)
+
+// Metric describes a metric of the catalog
+type Metric struct {
+	Name   string
+	Type   Type
+	Help   string
+	Labels []string
+	// Source is the hook or API of the locker the metric is fed from
+	Source string
+}
+
+// Labels of the catalog's metrics
+const (
+	// LabelOp is the lock operation, see gomysqllock.LockOp
+	LabelOp = "op"
+	// LabelOutcome is "ok" or "error"
+	LabelOutcome = "outcome"
+	// LabelHealthy is "true" or "false"
+	LabelHealthy = "healthy"
+)
+
+// Catalog returns every metric of the catalog. The metrics are built on every call, so that callers can not change
+// each other's.
+func Catalog() []Metric {
+	return []Metric{
+		{
+			Name:   Namespace + "_locks_held",
+			Type:   Gauge,
+			Help:   "Number of locks currently held through the locker.",
+			Source: "MysqlLocker.HeldLocks",
+		},
+		{
+			Name:   Namespace + "_lock_connections",
+			Type:   Gauge,
+			Help:   "Number of pool connections pinned by held locks.",
+			Source: "MysqlLocker.PoolStats",
+		},
+		{
+			Name:   Namespace + "_operation_duration_seconds",
+			Type:   Histogram,
+			Help:   "Duration of lock operations (obtain, release, heartbeat).",
+			Labels: []string{LabelOp, LabelOutcome},
+			Source: "WithMiddleware",
+		},
+		{
+			Name:   Namespace + "_health_transitions_total",
+			Type:   Counter,
+			Help:   "Number of times held locks turned unhealthy or healthy again.",
+			Labels: []string{LabelHealthy},
+			Source: "WithHealthHook",
+		},
+		{
+			Name:   Namespace + "_acquire_slo_violations_total",
+			Type:   Counter,
+			Help:   "Number of times the time-to-acquire SLO was violated.",
+			Source: "WithAcquireSLO",
+		},
+		{
+			Name:   Namespace + "_lock_pinned_seconds",
+			Type:   Histogram,
+			Help:   "How long ended locks pinned their connection.",
+			Source: "WithPinStatsHook",
+		},
+		{
+			Name:   Namespace + "_lock_idle_before_work_seconds",
+			Type:   Histogram,
+			Help:   "How long ended locks were held before the work they guard first reported progress.",
+			Source: "WithPinStatsHook",
+		},
+		{
+			Name:   Namespace + "_fail_open_total",
+			Type:   Counter,
+			Help:   "Number of degraded locks handed out instead of failing.",
+			Source: "WithFailOpen",
+		},
+		{
+			Name:   Namespace + "_topology_changes_total",
+			Type:   Counter,
+			Help:   "Number of changes of the server reached by the locker.",
+			Source: "WithTopologyHook",
+		},
+		{
+			Name:   Namespace + "_canary_duration_seconds",
+			Type:   Histogram,
+			Help:   "Duration of canary probes obtaining and releasing the probe key.",
+			Labels: []string{LabelOutcome},
+			Source: "WithCanary",
+		},
+	}
+}
+
+// WriteMarkdown documents the metrics as a Markdown table
+func WriteMarkdown(w io.Writer, catalog []Metric) error {
+	_, err := fmt.Fprintln(w, "| Name | Type | Labels | Source | Description |\n| --- | --- | --- | --- | --- |")
+	if err != nil {
+		return err
+	}
+	for _, metric := range catalog {
+		_, err := fmt.Fprintf(w, "| `%s` | %s | %s | `%s` | %s |\n",
+			metric.Name, metric.Type, strings.Join(metric.Labels, ", "), metric.Source, metric.Help)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
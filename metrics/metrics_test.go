@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalog(t *testing.T) {
+	names := make(map[string]bool)
+	for _, metric := range Catalog() {
+		assert.True(t, strings.HasPrefix(metric.Name, Namespace+"_"), metric.Name)
+		assert.False(t, names[metric.Name], "duplicate metric %s", metric.Name)
+		names[metric.Name] = true
+
+		if metric.Type == Counter {
+			assert.True(t, strings.HasSuffix(metric.Name, "_total"), "counter %s shall end with _total", metric.Name)
+		}
+	}
+
+	Catalog()[2].Labels[0] = "changed"
+	assert.NotEqual(t, "changed", Catalog()[2].Labels[0], "callers shall not change the catalog")
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf strings.Builder
+	var catalog []Metric
+	for _, metric := range Catalog() {
+		if metric.Name == Namespace+"_operation_duration_seconds" {
+			catalog = append(catalog, metric)
+		}
+	}
+	assert.NoError(t, WriteMarkdown(&buf, catalog))
+	assert.Equal(t, "| Name | Type | Labels | Source | Description |\n| --- | --- | --- | --- | --- |\n"+
+		"| `gomysqllock_operation_duration_seconds` | histogram | op, outcome | `WithMiddleware` | "+
+		"Duration of lock operations (obtain, release, heartbeat). |\n", buf.String())
+}
@@ -0,0 +1,39 @@
+package gomysqllock
+
+import "context"
+
+// LockOp is a lock operation passed through middlewares
+type LockOp string
+
+// Lock operations passed through middlewares
+const (
+	OpObtain    LockOp = "obtain"
+	OpRelease   LockOp = "release"
+	OpHeartbeat LockOp = "heartbeat"
+)
+
+// LockHandler performs a lock operation on a key
+type LockHandler func(ctx context.Context, op LockOp, key string) error
+
+// LockMiddleware wraps a LockHandler with a cross-cutting concern (auth checks, quotas, logging, chaos...), in the same
+// way as http.Handler middlewares: it can act before and after calling next, or return an error instead of calling it.
+type LockMiddleware func(next LockHandler) LockHandler
+
+// WithMiddleware wraps the locker's obtain, release and heartbeat operations with the given middlewares. The first
+// middleware is the outermost one. An error returned around a successful obtain releases the lock, and one returned
+// around a heartbeat loses the lock like a failed ping. Release can not be prevented: if a middleware does not call
+// next, the lock is released anyway and the middleware's error is returned. An obtain whose middleware returns nil
+// without calling next fails with ErrObtainSkipped, and calling next again releases the lock obtained by the previous
+// call first.
+func WithMiddleware(middlewares ...LockMiddleware) lockerOpt {
+	return func(l *MysqlLocker) { l.middlewares = append(l.middlewares, middlewares...) }
+}
+
+// runMiddlewares runs the operation through the locker's middlewares, with core as the innermost handler
+func (l MysqlLocker) runMiddlewares(ctx context.Context, op LockOp, key string, core LockHandler) error {
+	handler := core
+	for i := len(l.middlewares) - 1; i >= 0; i-- {
+		handler = l.middlewares[i](handler)
+	}
+	return handler(ctx, op, key)
+}
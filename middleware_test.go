@@ -0,0 +1,20 @@
+package gomysqllock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMysqlLocker_MiddlewareSkipsObtain(t *testing.T) {
+	locker := NewMysqlLocker(nil, WithFailOpen([]string{"*"}, nil), WithMiddleware(func(next LockHandler) LockHandler {
+		return func(ctx context.Context, op LockOp, key string) error {
+			return nil
+		}
+	}))
+
+	lock, err := locker.obtain(context.Background(), nil, "job", -1)
+	assert.Nil(t, lock)
+	assert.Equal(t, ErrObtainSkipped, err, "a skipped obtain shall fail, and not fail open")
+}
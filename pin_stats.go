@@ -0,0 +1,57 @@
+package gomysqllock
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// PinStats tells how long a lock pinned its connection, compared to when the work it guards reported progress. A long
+// UntilFirstProgress or AfterLastProgress points at code obtaining locks earlier, or releasing them later, than it
+// needs to, keeping pool connections hostage.
+type PinStats struct {
+	ObtainedAt time.Time
+	// ReleasedAt is zero while the lock is held
+	ReleasedAt time.Time
+	// Pinned is how long the lock has held its connection, until now if it is still held
+	Pinned time.Duration
+	// Heartbeats is the number of successful heartbeats
+	Heartbeats int64
+	// FirstProgress and LastProgress are the first and last calls to Progress (KeepAliveFor calls it too), they are
+	// zero if it was never called
+	FirstProgress time.Time
+	LastProgress  time.Time
+	// UntilFirstProgress is how long the lock was held before the first Progress call, or all along without any
+	UntilFirstProgress time.Duration
+	// AfterLastProgress is how long the lock was held after the last Progress call, or all along without any
+	AfterLastProgress time.Duration
+}
+
+// WithPinStatsHook sets a function called with the PinStats of every lock obtained through the locker once it is
+// released or lost, to be exported as metrics
+func WithPinStatsHook(hook func(lock *Lock, stats PinStats)) lockerOpt {
+	return func(l *MysqlLocker) { l.pinStatsHook = hook }
+}
+
+// PinStats returns how long the lock pinned its connection so far, compared to the progress of the work it guards
+func (l *Lock) PinStats() PinStats {
+	stats := PinStats{
+		ObtainedAt: l.obtainedAt,
+		Heartbeats: atomic.LoadInt64(&l.heartbeats),
+	}
+
+	end := time.Now()
+	if releasedAt := atomic.LoadInt64(&l.releasedAt); releasedAt != 0 {
+		stats.ReleasedAt = time.Unix(0, releasedAt)
+		end = stats.ReleasedAt
+	}
+	stats.Pinned = end.Sub(l.obtainedAt)
+
+	stats.UntilFirstProgress, stats.AfterLastProgress = stats.Pinned, stats.Pinned
+	if first := atomic.LoadInt64(&l.firstProgress); first != 0 {
+		stats.FirstProgress = time.Unix(0, first)
+		stats.LastProgress = time.Unix(0, atomic.LoadInt64(&l.lastProgress))
+		stats.UntilFirstProgress = stats.FirstProgress.Sub(l.obtainedAt)
+		stats.AfterLastProgress = end.Sub(stats.LastProgress)
+	}
+	return stats
+}
@@ -0,0 +1,165 @@
+package gomysqllock
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Promise signals the completion of work done somewhere in the fleet: a producer holds the promise's key while working
+// and records a status when done, and any number of nodes can await that status. Promises need a promise table, see
+// WithPromiseTable.
+type Promise struct {
+	locker MysqlLocker
+	key    string
+	lock   *Lock
+}
+
+// WithPromiseTable enables promises, whose state is stored in the given table. The table name is used verbatim in
+// queries and must come from trusted configuration. The table can be created with CreatePromiseTable.
+func WithPromiseTable(table string) lockerOpt {
+	return func(l *MysqlLocker) { l.promiseTable = table }
+}
+
+// CreatePromiseTable creates the promise table configured with WithPromiseTable, if it does not exist yet. Each row
+// holds the latest run of a promise, along with the connection of its producer so that abandoned runs are recognised.
+func (l MysqlLocker) CreatePromiseTable(ctx context.Context) error {
+	if l.promiseTable == "" {
+		return ErrPromiseNotConfigured
+	}
+
+	_, err := l.db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		lock_key VARCHAR(64) NOT NULL PRIMARY KEY,
+		connection_id BIGINT UNSIGNED NOT NULL,
+		owner VARCHAR(255) NOT NULL,
+		status TEXT NULL,
+		started_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+		completed_at TIMESTAMP(6) NULL
+	)`, l.promiseTable))
+	if err != nil {
+		return fmt.Errorf("failed to create promise table: %w", err)
+	}
+	return nil
+}
+
+// Promise returns the promise of the given key, for producing or awaiting it
+func (l MysqlLocker) Promise(key string) *Promise {
+	return &Promise{locker: l, key: key}
+}
+
+// Start obtains the promise's key, like ObtainContext, and records a new pending run. Nodes awaiting the promise wait
+// until the run is completed. The returned context is cancelled if the lock is lost, the run is abandoned then.
+func (p *Promise) Start(ctx context.Context) (context.Context, error) {
+	if p.locker.promiseTable == "" {
+		return nil, ErrPromiseNotConfigured
+	}
+
+	lock, err := p.locker.ObtainContext(ctx, p.key)
+	if err != nil {
+		return nil, err
+	}
+	if lock.Degraded() {
+		lock.Release()
+		return nil, ErrLockDegraded
+	}
+
+	_, err = lock.conn.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (lock_key, connection_id, owner) VALUES (?, CONNECTION_ID(), ?) "+
+			"ON DUPLICATE KEY UPDATE connection_id = VALUES(connection_id), owner = VALUES(owner), status = NULL, "+
+			"started_at = CURRENT_TIMESTAMP(6), completed_at = NULL",
+		p.locker.promiseTable), lock.name, p.locker.owner)
+	if err != nil {
+		lock.Release()
+		return nil, fmt.Errorf("failed to start promise: %w", err)
+	}
+
+	p.lock = lock
+	return lock.GetContext(), nil
+}
+
+// Complete records the status of the run started with Start and releases the promise's key, waking up the nodes
+// awaiting it. ErrPromiseAbandoned is returned if the lock was lost in the meantime, the status is not recorded then.
+func (p *Promise) Complete(ctx context.Context, status string) error {
+	if p.lock == nil {
+		return ErrPromiseAbandoned
+	}
+	defer p.lock.Release()
+
+	res, err := p.lock.conn.ExecContext(ctx, fmt.Sprintf(
+		"UPDATE %s SET status = ?, completed_at = CURRENT_TIMESTAMP(6) "+
+			"WHERE lock_key = ? AND connection_id = CONNECTION_ID() AND completed_at IS NULL",
+		p.locker.promiseTable), status, p.lock.name)
+	if err != nil {
+		select {
+		case <-p.lock.GetContext().Done():
+			return ErrPromiseAbandoned
+		default:
+		}
+		return fmt.Errorf("failed to complete promise: %w", err)
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return ErrPromiseAbandoned
+	}
+	return nil
+}
+
+// Await blocks until the promise's latest run is completed and returns its status, checking at the locker's refresh
+// interval. If that run is already completed, its status is returned right away. ErrPromiseAbandoned is returned when
+// the producer of the pending run no longer holds the key.
+func (p *Promise) Await(ctx context.Context) (string, error) {
+	if p.locker.promiseTable == "" {
+		return "", ErrPromiseNotConfigured
+	}
+	if err := p.locker.validateKey(p.key); err != nil {
+		return "", err
+	}
+	name := p.locker.lockName(p.key)
+
+	for {
+		producer, status, err := p.read(ctx, name)
+		if err != nil {
+			return "", err
+		} else if status.Valid {
+			return status.String, nil
+		}
+
+		if producer != 0 {
+			holder, err := p.locker.holderConnectionID(ctx, p.key)
+			if err != nil {
+				return "", err
+			}
+			if holder != producer {
+				// the producer may have completed the run, or a new run may have started, since it was read
+				current, status, err := p.read(ctx, name)
+				if err != nil {
+					return "", err
+				} else if status.Valid {
+					return status.String, nil
+				} else if current == producer {
+					return "", ErrPromiseAbandoned
+				}
+				continue
+			}
+		}
+
+		// the promise was never started, or its run is pending
+		if err := sleepContext(ctx, p.locker.config().refreshInterval); err != nil {
+			return "", err
+		}
+	}
+}
+
+// read returns the producer's connection id and the status of the promise's latest run, the connection id is 0 if
+// the promise was never started
+func (p *Promise) read(ctx context.Context, name string) (int64, sql.NullString, error) {
+	var producer int64
+	var status sql.NullString
+	err := p.locker.db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT connection_id, status FROM %s WHERE lock_key = ?", p.locker.promiseTable),
+		name).Scan(&producer, &status)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, status, fmt.Errorf("could not read promise: %w", err)
+	}
+	return producer, status, nil
+}
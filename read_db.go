@@ -0,0 +1,45 @@
+package gomysqllock
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// WithReadDB sets a second, read-only database (typically a replica) used for reading the locker's tables:
+// HolderMetadata, EstimateWait, HistoryReport and Watch. Results read from it may lag behind the primary.
+// Named locks only exist on the server which granted them and are not replicated, so whether a key is locked, who holds
+// it and who waits for it are always checked on the primary.
+func WithReadDB(db *sql.DB) lockerOpt {
+	return func(l *MysqlLocker) { l.readOnlyDB = db }
+}
+
+// readDB returns the database the locker's tables are read from
+func (l MysqlLocker) readDB() *sql.DB {
+	if l.readOnlyDB != nil {
+		return l.readOnlyDB
+	}
+	return l.db
+}
+
+// holderConnectionID returns the id of the primary's connection holding the key's lock, or 0 if it is free
+func (l MysqlLocker) holderConnectionID(ctx context.Context, key string) (int64, error) {
+	var connectionID int64
+	row, err := l.queryRow(ctx, isUsedLockQuery, l.lockName(key))
+	if err == nil {
+		err = row.Scan(&connectionID)
+	}
+	if err != nil {
+		if keyErr := keyError(key, err); keyErr != nil {
+			return 0, keyErr
+		}
+		if unsupportedErr := unsupportedError("IS_USED_LOCK", err); unsupportedErr != nil {
+			return 0, unsupportedErr
+		}
+		return 0, fmt.Errorf("could not read mysql response: %w", err)
+	}
+	if connectionID == -1 {
+		return 0, nil
+	}
+	return connectionID, nil
+}
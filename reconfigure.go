@@ -0,0 +1,113 @@
+package gomysqllock
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// liveConfig holds the settings which can be changed at runtime with Reconfigure. It is kept in the locker's shared
+// state and read by the refreshers at every heartbeat.
+type liveConfig struct {
+	refreshInterval    time.Duration
+	healthThreshold    time.Duration
+	adaptiveRefreshMin time.Duration
+	adaptiveRefreshMax time.Duration
+	releaseConcurrency int
+	failOpenPatterns   []string
+	failOpenHook       func(key string, err error)
+}
+
+// Reconfigure changes settings of a running locker, so that long-lived processes can be tuned without restarting and
+// releasing their locks. It applies the given options for the refresh interval, health threshold, adaptive refresh
+// and release concurrency, which locks already held pick up from their next heartbeat on, and for failing open.
+// Other options only take effect when the locker is created: given one, Reconfigure fails with ErrNotReconfigurable.
+// It fails with ErrInvalidConfig when the resulting settings are invalid. Settings are only changed when it succeeds.
+func (l MysqlLocker) Reconfigure(opts ...lockerOpt) error {
+	for i, opt := range opts {
+		if !reconfigurable(opt) {
+			return fmt.Errorf("option %d: %w", i, ErrNotReconfigurable)
+		}
+	}
+
+	l.state.mu.Lock()
+	defer l.state.mu.Unlock()
+
+	staged := MysqlLocker{state: l.state}
+	staged.setLiveConfig(l.state.live)
+	for _, opt := range opts {
+		opt(&staged)
+	}
+	config := staged.stagedLiveConfig()
+	if err := config.validate(); err != nil {
+		return err
+	}
+	l.state.live = config
+	return nil
+}
+
+// reconfigurable reports whether the option only changes runtime settings, by applying it to an empty locker
+func reconfigurable(opt lockerOpt) bool {
+	var probe MysqlLocker
+	opt(&probe)
+	probe.setLiveConfig(liveConfig{})
+	return reflect.DeepEqual(probe, MysqlLocker{})
+}
+
+// validate checks that the runtime settings can be used by refreshers and releases
+func (c liveConfig) validate() error {
+	switch {
+	case c.refreshInterval <= 0:
+		return fmt.Errorf("refresh interval must be positive: %w", ErrInvalidConfig)
+	case c.healthThreshold < 0:
+		return fmt.Errorf("health threshold must not be negative: %w", ErrInvalidConfig)
+	case c.adaptive() && (c.adaptiveRefreshMin < minAdaptiveRefresh || c.adaptiveRefreshMin > c.adaptiveRefreshMax):
+		return fmt.Errorf("adaptive refresh bounds must be ordered and at least %s: %w", minAdaptiveRefresh,
+			ErrInvalidConfig)
+	case c.releaseConcurrency < 1:
+		return fmt.Errorf("release concurrency must be at least 1: %w", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// config returns the current runtime settings of the locker
+func (l MysqlLocker) config() liveConfig {
+	l.state.mu.Lock()
+	config := l.state.live
+	l.state.mu.Unlock()
+
+	if config.healthThreshold == 0 {
+		// heartbeats slower than the refresh interval lose the lock
+		config.healthThreshold = config.refreshInterval / 2
+	}
+	return config
+}
+
+// adaptive reports whether adaptive refresh is enabled
+func (c liveConfig) adaptive() bool {
+	return c.adaptiveRefreshMax > 0
+}
+
+// stagedLiveConfig returns the runtime settings set on the locker by options
+func (l *MysqlLocker) stagedLiveConfig() liveConfig {
+	return liveConfig{
+		refreshInterval:    l.refreshInterval,
+		healthThreshold:    l.healthThreshold,
+		adaptiveRefreshMin: l.adaptiveRefreshMin,
+		adaptiveRefreshMax: l.adaptiveRefreshMax,
+		releaseConcurrency: l.releaseConcurrency,
+		failOpenPatterns:   l.failOpenPatterns,
+		failOpenHook:       l.failOpenHook,
+	}
+}
+
+// setLiveConfig sets the runtime settings on the locker, for options to change them
+func (l *MysqlLocker) setLiveConfig(config liveConfig) {
+	l.refreshInterval = config.refreshInterval
+	l.healthThreshold = config.healthThreshold
+	l.adaptiveRefreshMin = config.adaptiveRefreshMin
+	l.adaptiveRefreshMax = config.adaptiveRefreshMax
+	l.releaseConcurrency = config.releaseConcurrency
+	l.failOpenPatterns = config.failOpenPatterns
+	l.failOpenHook = config.failOpenHook
+}
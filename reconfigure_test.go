@@ -0,0 +1,42 @@
+package gomysqllock
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMysqlLocker_Reconfigure(t *testing.T) {
+	locker := NewMysqlLocker(nil, WithRefreshInterval(time.Second), WithReleaseConcurrency(4))
+	assert.Equal(t, time.Millisecond*500, locker.config().healthThreshold)
+
+	assert.NoError(t, locker.Reconfigure(WithRefreshInterval(time.Second*2)))
+	config := locker.config()
+	assert.Equal(t, time.Second*2, config.refreshInterval)
+	assert.Equal(t, time.Second, config.healthThreshold, "default health threshold shall follow the refresh interval")
+	assert.Equal(t, 4, config.releaseConcurrency, "settings not given shall be kept")
+
+	assert.NoError(t, locker.Reconfigure(WithHealthThreshold(time.Millisecond*300),
+		WithAdaptiveRefresh(time.Millisecond, time.Second)))
+	config = locker.config()
+	assert.Equal(t, time.Millisecond*300, config.healthThreshold)
+	assert.True(t, config.adaptive())
+}
+
+func TestMysqlLocker_ReconfigureRejected(t *testing.T) {
+	locker := NewMysqlLocker(nil, WithRefreshInterval(time.Second))
+
+	err := locker.Reconfigure(WithRefreshInterval(time.Second*2), WithKeyPolicy("*", KeyPolicy{Deny: true}))
+	assert.True(t, errors.Is(err, ErrNotReconfigurable), "options which can not change at runtime shall be reported")
+	assert.Empty(t, locker.keyPolicies)
+	assert.Equal(t, time.Second, locker.config().refreshInterval, "a rejected call shall change nothing")
+
+	err = locker.Reconfigure(WithRefreshInterval(time.Second*2), WithReleaseConcurrency(0))
+	assert.True(t, errors.Is(err, ErrInvalidConfig), "invalid settings shall be rejected")
+	assert.Equal(t, time.Second, locker.config().refreshInterval, "a rejected call shall change nothing")
+
+	assert.True(t, errors.Is(locker.Reconfigure(WithRefreshInterval(0)), ErrInvalidConfig))
+	assert.True(t, errors.Is(locker.Reconfigure(WithHealthThreshold(-time.Second)), ErrInvalidConfig))
+}
@@ -0,0 +1,62 @@
+package gomysqllock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultReleaseConcurrency is the number of locks released at once by MysqlLocker.Release
+const DefaultReleaseConcurrency = 8
+
+// DefaultReleaseTimeout bounds the queries run by Lock.ReleaseDetached
+const DefaultReleaseTimeout = 5 * time.Second
+
+// WithReleaseConcurrency sets the number of locks released at once by MysqlLocker.Release, values below 1 release
+// one lock at a time
+func WithReleaseConcurrency(n int) lockerOpt {
+	return func(l *MysqlLocker) { l.releaseConcurrency = n }
+}
+
+// Release releases the given locks concurrently, with at most the configured release concurrency, and returns the
+// outcome of each release by key (nil on success). Locks whose release has not started when ctx is done are not
+// released, their outcome is the context's error. Locks sharing a key, for example obtained through lockers with
+// different namespaces, share an entry: it holds an error if the release of any of them failed.
+func (l MysqlLocker) Release(ctx context.Context, locks ...*Lock) map[string]error {
+	results := make(map[string]error, len(locks))
+	var resultsMu sync.Mutex
+	setResult := func(key string, err error) {
+		resultsMu.Lock()
+		if results[key] == nil {
+			results[key] = err
+		}
+		resultsMu.Unlock()
+	}
+
+	concurrency := l.config().releaseConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, lock := range locks {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			setResult(lock.key, ctx.Err())
+			continue
+		}
+
+		wg.Add(1)
+		go func(lock *Lock) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			setResult(lock.key, lock.Release())
+		}(lock)
+	}
+	wg.Wait()
+
+	return results
+}
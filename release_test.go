@@ -0,0 +1,22 @@
+package gomysqllock
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMysqlLocker_ReleaseConcurrencyBelowOne(t *testing.T) {
+	errBroken := errors.New("broken")
+	for _, n := range []int{0, -1} {
+		locker := NewMysqlLocker(nil, WithReleaseConcurrency(n), WithFailOpen([]string{"*"}, nil))
+		first, second := locker.failOpen(context.Background(), "first", errBroken),
+			locker.failOpen(context.Background(), "second", errBroken)
+
+		results := locker.Release(context.Background(), first, second)
+		assert.Equal(t, map[string]error{"first": nil, "second": nil}, results,
+			"locks shall be released one at a time")
+	}
+}
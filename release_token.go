@@ -0,0 +1,105 @@
+package gomysqllock
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// releaseTokenBytes is the number of random bytes in release tokens
+const releaseTokenBytes = 16
+
+// ReleaseToken returns the token issued to the lock because of a key policy's RequireReleaseToken, or "" if none was
+func (l *Lock) ReleaseToken() string {
+	return l.releaseToken
+}
+
+// issueReleaseToken generates the lock's release token and stores it in the metadata table
+func (l *Lock) issueReleaseToken(ctx context.Context) error {
+	token := make([]byte, releaseTokenBytes)
+	if _, err := rand.Read(token); err != nil {
+		return fmt.Errorf("failed to generate a release token: %w", err)
+	}
+	l.releaseToken = hex.EncodeToString(token)
+
+	if err := l.writeMetadata(ctx, nil); err != nil {
+		return fmt.Errorf("failed to store release token: %w", err)
+	}
+	return nil
+}
+
+// writeMetadata upserts the metadata row of a lock with a release token, along with the given payload. Locks without
+// a token do not write the release_token column, so that metadata tables created before it existed keep working.
+func (l *Lock) writeMetadata(ctx context.Context, payload []byte) error {
+	_, err := l.bookkeeping().ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (lock_key, connection_id, owner, payload, release_token) VALUES (?, ?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE connection_id = VALUES(connection_id), owner = VALUES(owner), "+
+			"payload = VALUES(payload), release_token = VALUES(release_token)",
+		l.locker.metadataTable), l.name, l.connectionID, l.locker.owner, payload, l.releaseToken)
+	return err
+}
+
+// ForceRelease releases the lock on key held by any process, by killing the holder's MySQL connection, for operational
+// tooling. When the holder was issued a release token (see KeyPolicy.RequireReleaseToken), token must match it or
+// ErrReleaseTokenMismatch is returned, so that components can not release each other's locks by accident. The lock is
+// recorded as forced in the history table. Nothing is done if the key is not locked, or changes hands while the token
+// is checked. A holder releasing the lock in the short window between the last check and the KILL still loses its
+// connection, along with whatever runs on it by then.
+// Killing other users' connections needs the CONNECTION_ADMIN (or SUPER) privilege, ErrUnsupportedByServer is returned
+// without it.
+func (l MysqlLocker) ForceRelease(ctx context.Context, key, token string) error {
+	if l.metadataTable == "" {
+		return ErrMetadataNotConfigured
+	}
+	if err := l.validateKey(key); err != nil {
+		return err
+	}
+	name := l.lockName(key)
+
+	holder, err := l.holderConnectionID(ctx, key)
+	if err != nil || holder == 0 {
+		return err
+	}
+
+	var expected sql.NullString
+	err = l.db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT release_token FROM %s WHERE lock_key = ? AND connection_id = ?", l.metadataTable),
+		name, holder).Scan(&expected)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("could not read release token: %w", err)
+	}
+
+	rule, err := l.matchKeyPolicy(key)
+	if err != nil {
+		return err
+	}
+	required := rule != nil && rule.policy.RequireReleaseToken
+	if (expected.Valid || required) && token != expected.String {
+		return ErrReleaseTokenMismatch
+	}
+
+	// the holder may have released the lock while its token was checked, its connection must not be killed then
+	current, err := l.holderConnectionID(ctx, key)
+	if err != nil || current != holder {
+		return err
+	}
+
+	// connection ids are integers read from the server, KILL does not take placeholders
+	if _, err := l.db.ExecContext(ctx, fmt.Sprintf("KILL %d", holder)); err != nil {
+		if unsupportedErr := unsupportedError("KILL", err); unsupportedErr != nil {
+			return unsupportedErr
+		}
+		return fmt.Errorf("failed to kill the holder's connection: %w", err)
+	}
+
+	if l.historyTable != "" {
+		l.db.ExecContext(ctx, fmt.Sprintf(
+			"UPDATE %s SET released_at = CURRENT_TIMESTAMP(6), outcome = ? "+
+				"WHERE lock_key = ? AND connection_id = ? AND released_at IS NULL", l.historyTable),
+			OutcomeForced, name, holder)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package gomysqllock
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// HistoryReport aggregates the holds recorded in the history table over a time range
+type HistoryReport struct {
+	From  time.Time
+	Until time.Time
+	// FromReadDB tells that the report was read from the database set with WithReadDB, so the most recent holds may be
+	// missing from it
+	FromReadDB bool
+	// Keys holds a report per key, the most held (contended) keys first
+	Keys []KeyReport
+}
+
+// KeyReport aggregates the holds of a single key
+type KeyReport struct {
+	Key string
+	// Holds is the number of completed holds
+	Holds int
+	// Owners is the number of distinct owners which held the key, more than one means the key was contended
+	Owners int
+	// Losses is the number of holds which ended with the lock being lost
+	Losses  int
+	P50Hold time.Duration
+	P95Hold time.Duration
+	MaxHold time.Duration
+}
+
+// historyRecord is a completed hold read from the history table
+type historyRecord struct {
+	key     string
+	owner   string
+	outcome string
+	hold    time.Duration
+}
+
+// HistoryReport reads the holds which started between from and until and ended since, and aggregates them per key.
+// It only needs the history table, so it can be used for capacity reviews without any live metrics.
+func (l MysqlLocker) HistoryReport(ctx context.Context, from, until time.Time) (*HistoryReport, error) {
+	if l.historyTable == "" {
+		return nil, ErrHistoryNotConfigured
+	}
+
+	// FROM_UNIXTIME works in the session's time zone, just like the TIMESTAMP columns it is compared to
+	rows, err := l.readDB().QueryContext(ctx, fmt.Sprintf(
+		"SELECT lock_key, owner, outcome, TIMESTAMPDIFF(MICROSECOND, obtained_at, released_at) FROM %s "+
+			"WHERE obtained_at >= FROM_UNIXTIME(? / 1000000) AND obtained_at < FROM_UNIXTIME(? / 1000000) "+
+			"AND released_at IS NOT NULL", l.historyTable),
+		from.UnixNano()/int64(time.Microsecond), until.UnixNano()/int64(time.Microsecond))
+	if err != nil {
+		return nil, fmt.Errorf("could not read lock history: %w", err)
+	}
+	defer rows.Close()
+
+	var records []historyRecord
+	for rows.Next() {
+		var record historyRecord
+		var holdMicros int64
+		if err := rows.Scan(&record.key, &record.owner, &record.outcome, &holdMicros); err != nil {
+			return nil, fmt.Errorf("could not read lock history: %w", err)
+		}
+		record.hold = time.Duration(holdMicros) * time.Microsecond
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read lock history: %w", err)
+	}
+
+	return &HistoryReport{
+		From:       from,
+		Until:      until,
+		FromReadDB: l.readOnlyDB != nil,
+		Keys:       aggregateHistory(records),
+	}, nil
+}
+
+// aggregateHistory builds the per key reports of the given holds
+func aggregateHistory(records []historyRecord) []KeyReport {
+	holds := make(map[string][]time.Duration)
+	owners := make(map[string]map[string]bool)
+	losses := make(map[string]int)
+
+	for _, record := range records {
+		holds[record.key] = append(holds[record.key], record.hold)
+		if owners[record.key] == nil {
+			owners[record.key] = make(map[string]bool)
+		}
+		owners[record.key][record.owner] = true
+		if record.outcome == OutcomeLost {
+			losses[record.key]++
+		}
+	}
+
+	reports := make([]KeyReport, 0, len(holds))
+	for key, durations := range holds {
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		reports = append(reports, KeyReport{
+			Key:     key,
+			Holds:   len(durations),
+			Owners:  len(owners[key]),
+			Losses:  losses[key],
+			P50Hold: percentile(durations, 50),
+			P95Hold: percentile(durations, 95),
+			MaxHold: durations[len(durations)-1],
+		})
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Holds != reports[j].Holds {
+			return reports[i].Holds > reports[j].Holds
+		}
+		return reports[i].Key < reports[j].Key
+	})
+	return reports
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// WriteJSON writes the report as JSON
+func (r *HistoryReport) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+// WriteCSV writes the key reports as CSV with a header row, durations are in milliseconds
+func (r *HistoryReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"key", "holds", "owners", "losses", "p50_hold_ms", "p95_hold_ms", "max_hold_ms"})
+	for _, key := range r.Keys {
+		writer.Write([]string{
+			key.Key,
+			strconv.Itoa(key.Holds),
+			strconv.Itoa(key.Owners),
+			strconv.Itoa(key.Losses),
+			strconv.FormatInt(int64(key.P50Hold/time.Millisecond), 10),
+			strconv.FormatInt(int64(key.P95Hold/time.Millisecond), 10),
+			strconv.FormatInt(int64(key.MaxHold/time.Millisecond), 10),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
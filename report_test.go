@@ -0,0 +1,38 @@
+package gomysqllock
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateHistory(t *testing.T) {
+	var records []historyRecord
+	for i := 1; i <= 20; i++ {
+		records = append(records, historyRecord{key: "busy", owner: "a", outcome: OutcomeReleased,
+			hold: time.Duration(i) * time.Second})
+	}
+	records = append(records,
+		historyRecord{key: "quiet", owner: "a", outcome: OutcomeReleased, hold: time.Second},
+		historyRecord{key: "quiet", owner: "b", outcome: OutcomeLost, hold: time.Second * 3},
+	)
+
+	reports := aggregateHistory(records)
+	assert.Equal(t, []KeyReport{
+		{Key: "busy", Holds: 20, Owners: 1, P50Hold: time.Second * 10, P95Hold: time.Second * 19, MaxHold: time.Second * 20},
+		{Key: "quiet", Holds: 2, Owners: 2, Losses: 1, P50Hold: time.Second, P95Hold: time.Second * 3,
+			MaxHold: time.Second * 3},
+	}, reports)
+}
+
+func TestHistoryReport_WriteCSV(t *testing.T) {
+	report := &HistoryReport{Keys: []KeyReport{{Key: "key", Holds: 2, Owners: 1, P50Hold: time.Second,
+		P95Hold: time.Second * 2, MaxHold: time.Second * 2}}}
+
+	var buf bytes.Buffer
+	assert.NoError(t, report.WriteCSV(&buf))
+	assert.Equal(t, "key,holds,owners,losses,p50_hold_ms,p95_hold_ms,max_hold_ms\nkey,2,1,0,1000,2000,2000\n",
+		buf.String())
+}
@@ -0,0 +1,88 @@
+package gomysqllock
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+)
+
+// RoutedLocker is a locker facade for sharded/multi-primary deployments, it sends each key's lock operations to the
+// primary which owns that key
+type RoutedLocker struct {
+	lockers []*MysqlLocker
+	route   func(key string) int
+}
+
+// NewRoutedLocker returns a locker which routes lock operations over the given databases (one per primary). route maps
+// a key to the index of its database in dbs. The given options are applied to the locker of every primary.
+func NewRoutedLocker(dbs []*sql.DB, route func(key string) int, lockerOpts ...lockerOpt) *RoutedLocker {
+	lockers := make([]*MysqlLocker, len(dbs))
+	for i, db := range dbs {
+		lockers[i] = NewMysqlLocker(db, lockerOpts...)
+	}
+
+	return &RoutedLocker{
+		lockers: lockers,
+		route:   route,
+	}
+}
+
+// HashRoute returns a routing function which spreads keys over n primaries using an FNV-1a hash of the key. With n
+// below 1 there is no primary to route to, every key's operations then fail with ErrInvalidRoute.
+func HashRoute(n int) func(key string) int {
+	if n < 1 {
+		return func(string) int { return -1 }
+	}
+	return func(key string) int {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		return int(h.Sum32() % uint32(n))
+	}
+}
+
+// Locker returns the locker of the primary owning the given key
+func (r RoutedLocker) Locker(key string) (*MysqlLocker, error) {
+	i := r.route(key)
+	if i < 0 || i >= len(r.lockers) {
+		return nil, ErrInvalidRoute
+	}
+	return r.lockers[i], nil
+}
+
+// Obtain tries to acquire lock (with no MySQL timeout) on the key's primary with background context
+func (r RoutedLocker) Obtain(key string) (*Lock, error) {
+	return r.ObtainTimeoutContext(context.Background(), key, -1)
+}
+
+// ObtainTimeout tries to acquire lock on the key's primary with background context and a MySQL timeout
+func (r RoutedLocker) ObtainTimeout(key string, timeout int) (*Lock, error) {
+	return r.ObtainTimeoutContext(context.Background(), key, timeout)
+}
+
+// ObtainContext tries to acquire lock on the key's primary and gives up when the given context is cancelled
+func (r RoutedLocker) ObtainContext(ctx context.Context, key string) (*Lock, error) {
+	return r.ObtainTimeoutContext(ctx, key, -1)
+}
+
+// ObtainTimeoutContext tries to acquire lock on the key's primary and gives up when the given context is cancelled
+func (r RoutedLocker) ObtainTimeoutContext(ctx context.Context, key string, timeout int) (*Lock, error) {
+	locker, err := r.Locker(key)
+	if err != nil {
+		return nil, err
+	}
+	return locker.ObtainTimeoutContext(ctx, key, timeout)
+}
+
+// IsLocked checks whether the key is locked on its primary
+func (r RoutedLocker) IsLocked(key string) (bool, error) {
+	return r.IsLockedContext(context.Background(), key)
+}
+
+// IsLockedContext checks whether the key is locked on its primary and gives up when the given context is cancelled
+func (r RoutedLocker) IsLockedContext(ctx context.Context, key string) (bool, error) {
+	locker, err := r.Locker(key)
+	if err != nil {
+		return false, err
+	}
+	return locker.IsLockedContext(ctx, key)
+}
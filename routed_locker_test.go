@@ -0,0 +1,17 @@
+package gomysqllock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashRoute(t *testing.T) {
+	route := HashRoute(3)
+	assert.Equal(t, route("key"), route("key"))
+	assert.True(t, route("key") >= 0 && route("key") < 3)
+
+	locker := NewRoutedLocker(nil, HashRoute(0))
+	_, err := locker.Locker("key")
+	assert.Equal(t, ErrInvalidRoute, err, "routes without primaries shall not panic")
+}
@@ -0,0 +1,40 @@
+package gomysqllock
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// RunExclusive is a one-off helper for small binaries: it opens a connection pool to the given MySQL DSN, obtains the
+// lock on key, runs fn while holding it, then releases the lock and closes the pool. fn is given a context which is
+// cancelled when either ctx is done or the lock is lost.
+func RunExclusive(ctx context.Context, dsn string, key string, fn func(ctx context.Context) error) error {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open db: %w", err)
+	}
+	defer db.Close()
+
+	lock, err := NewMysqlLocker(db).ObtainContext(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	runContext, cancelFunc := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-lock.GetContext().Done():
+			cancelFunc()
+		case <-runContext.Done():
+		}
+	}()
+
+	err = fn(runContext)
+	cancelFunc()
+
+	if releaseErr := lock.Release(); err == nil {
+		err = releaseErr
+	}
+	return err
+}
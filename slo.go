@@ -0,0 +1,74 @@
+package gomysqllock
+
+import (
+	"sync"
+	"time"
+)
+
+// sloWindowSize is the number of most recent acquisitions over which the SLO violation rate is computed
+const sloWindowSize = 100
+
+// sloMinSamples is the number of acquisitions observed before the violation rate is trusted enough to alert
+const sloMinSamples = 20
+
+// acquireSLO tracks the rolling fraction of acquisitions which took longer than the target
+type acquireSLO struct {
+	target      time.Duration
+	threshold   float64
+	onViolation func(rate float64)
+
+	mu         sync.Mutex
+	violated   [sloWindowSize]bool
+	count      int
+	next       int
+	violations int
+	firing     bool
+}
+
+// WithAcquireSLO sets a time-to-acquire objective: onViolation is called with the violation rate when the fraction of
+// the last 100 acquisitions which took longer than d rises above threshold (between 0 and 1). Waits which ended
+// without the lock, because of a MySQL timeout or a cancelled context, count as violations once they exceeded d.
+// Nothing is reported before 20 acquisitions were observed. It is called again only after the rate has dropped back to
+// or below the threshold.
+func WithAcquireSLO(d time.Duration, threshold float64, onViolation func(rate float64)) lockerOpt {
+	return func(l *MysqlLocker) {
+		l.acquireSLO = &acquireSLO{target: d, threshold: threshold, onViolation: onViolation}
+	}
+}
+
+// observe records how long an acquisition took
+func (s *acquireSLO) observe(d time.Duration) {
+	violated := d > s.target
+
+	s.mu.Lock()
+	if s.count == sloWindowSize {
+		if s.violated[s.next] {
+			s.violations--
+		}
+	} else {
+		s.count++
+	}
+	s.violated[s.next] = violated
+	if violated {
+		s.violations++
+	}
+	s.next = (s.next + 1) % sloWindowSize
+
+	rate := float64(s.violations) / float64(s.count)
+	violating := s.count >= sloMinSamples && rate > s.threshold
+	trigger := violating && !s.firing
+	s.firing = violating
+	s.mu.Unlock()
+
+	if trigger && s.onViolation != nil {
+		s.onViolation(rate)
+	}
+}
+
+// observeFailure records a wait which ended without the lock. Only waits which exceeded the target are recorded, as
+// they violated it whatever came next, while shorter ones tell nothing about the time to acquire.
+func (s *acquireSLO) observeFailure(d time.Duration) {
+	if d > s.target {
+		s.observe(d)
+	}
+}
@@ -0,0 +1,55 @@
+package gomysqllock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquireSLO(t *testing.T) {
+	var rates []float64
+	slo := &acquireSLO{target: time.Second, threshold: 0.5, onViolation: func(rate float64) { rates = append(rates, rate) }}
+
+	for i := 0; i < sloMinSamples/2; i++ {
+		slo.observe(time.Millisecond)
+		slo.observe(time.Second * 2)
+	}
+	assert.Empty(t, rates, "a violation rate equal to the threshold shall not trigger")
+
+	slo.observe(time.Second * 2)
+	slo.observe(time.Second * 2)
+	assert.Equal(t, []float64{float64(sloMinSamples/2+1) / float64(sloMinSamples+1)}, rates,
+		"callback shall only trigger once while violating")
+
+	for i := 0; i < sloWindowSize; i++ {
+		slo.observe(time.Millisecond)
+	}
+	slo.observe(time.Second * 2)
+	assert.Len(t, rates, 1, "old violations shall leave the window")
+}
+
+func TestAcquireSLO_MinSamples(t *testing.T) {
+	var rates []float64
+	slo := &acquireSLO{target: time.Second, threshold: 0.5}
+	slo.onViolation = func(rate float64) { rates = append(rates, rate) }
+
+	for i := 1; i < sloMinSamples; i++ {
+		slo.observe(time.Second * 2)
+	}
+	assert.Empty(t, rates, "callback shall not trigger before the window has enough samples")
+
+	slo.observe(time.Second * 2)
+	assert.Equal(t, []float64{1}, rates)
+}
+
+func TestAcquireSLO_ObserveFailure(t *testing.T) {
+	slo := &acquireSLO{target: time.Second, threshold: 0.5}
+
+	slo.observeFailure(time.Millisecond)
+	assert.Equal(t, 0, slo.count, "failed waits shorter than the target shall not be recorded")
+
+	slo.observeFailure(time.Second * 2)
+	assert.Equal(t, 1, slo.count)
+	assert.Equal(t, 1, slo.violations, "failed waits longer than the target shall be recorded as violations")
+}
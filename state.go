@@ -0,0 +1,127 @@
+package gomysqllock
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// lockerState is the mutable state shared by all copies of a MysqlLocker and the locks obtained through it
+type lockerState struct {
+	// pinnedConns is accessed atomically, it is kept first for 64-bit alignment on 32-bit platforms
+	pinnedConns int64
+
+	mu       sync.Mutex
+	locks    map[*Lock]struct{}
+	shutdown bool
+	// done is closed on shutdown, to stop the locker's background goroutines
+	done chan struct{}
+	// live holds the settings which can be changed with Reconfigure
+	live liveConfig
+	// goroutines tracks the background goroutines of the locker and its locks
+	goroutines sync.WaitGroup
+
+	// stmtMu guards stmts, it is kept apart from mu as statements are prepared while holding it
+	stmtMu      sync.Mutex
+	stmts       map[string]*sql.Stmt
+	stmtsClosed bool
+}
+
+func newLockerState() *lockerState {
+	return &lockerState{
+		locks: make(map[*Lock]struct{}),
+		stmts: make(map[string]*sql.Stmt),
+		done:  make(chan struct{}),
+	}
+}
+
+// register adds a held lock to the registry, it fails once the locker is shut down
+func (s *lockerState) register(lock *Lock) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shutdown {
+		return false
+	}
+	s.locks[lock] = struct{}{}
+	return true
+}
+
+// unregister removes a lock from the registry once it is released or lost
+func (s *lockerState) unregister(lock *Lock) {
+	s.mu.Lock()
+	delete(s.locks, lock)
+	s.mu.Unlock()
+}
+
+// heldLocks returns the locks currently held through the locker
+func (s *lockerState) heldLocks() []*Lock {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	locks := make([]*Lock, 0, len(s.locks))
+	for lock := range s.locks {
+		locks = append(locks, lock)
+	}
+	return locks
+}
+
+// isShutdown reports whether Shutdown was called
+func (s *lockerState) isShutdown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shutdown
+}
+
+// goroutine runs f in a background goroutine which Shutdown waits for. Once the locker is shut down f is not run and
+// false is returned, so that Shutdown never waits for goroutines started while it waits.
+func (s *lockerState) goroutine(f func()) bool {
+	s.mu.Lock()
+	if s.shutdown {
+		s.mu.Unlock()
+		return false
+	}
+	s.goroutines.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.goroutines.Done()
+		f()
+	}()
+	return true
+}
+
+// Shutdown releases all locks held through the locker, stops all of its background goroutines (refreshers, watchers
+// and the like) and waits for them to exit, or for ctx to be done. Obtaining locks fails with ErrLockerShutdown
+// afterwards. The locker's *sql.DB is left open, it belongs to the caller.
+// Once Shutdown returned without error and the *sql.DB is closed, no goroutine started by this package is left
+// running, so test suites checking for goroutine leaks need no special handling.
+func (l MysqlLocker) Shutdown(ctx context.Context) error {
+	l.state.mu.Lock()
+	if !l.state.shutdown {
+		l.state.shutdown = true
+		close(l.state.done)
+	}
+	l.state.mu.Unlock()
+
+	var firstErr error
+	for _, lock := range l.state.heldLocks() {
+		if err := lock.Release(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		l.state.goroutines.Wait()
+		l.state.closeStmts()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return firstErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
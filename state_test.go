@@ -0,0 +1,18 @@
+package gomysqllock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockerState_GoroutineAfterShutdown(t *testing.T) {
+	locker := NewMysqlLocker(nil)
+	ran := make(chan struct{})
+	assert.True(t, locker.state.goroutine(func() { close(ran) }))
+	<-ran
+
+	assert.NoError(t, locker.Shutdown(context.Background()))
+	assert.False(t, locker.state.goroutine(func() { t.Error("goroutine shall not run after shutdown") }))
+}
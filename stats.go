@@ -0,0 +1,28 @@
+package gomysqllock
+
+import (
+	"database/sql"
+	"sync/atomic"
+)
+
+// PoolStats describes how the locker's connection pool is used
+type PoolStats struct {
+	sql.DBStats
+
+	// LockConnections is the number of pool connections currently pinned by locks obtained through this locker.
+	// Each held lock keeps its own connection open until it is released or lost.
+	LockConnections int
+}
+
+// PoolStats returns the database pool statistics along with the number of connections pinned by held locks
+func (l MysqlLocker) PoolStats() PoolStats {
+	return PoolStats{
+		DBStats:         l.db.Stats(),
+		LockConnections: int(atomic.LoadInt64(&l.state.pinnedConns)),
+	}
+}
+
+// HeldLocks returns the locks currently held through this locker, in no particular order
+func (l MysqlLocker) HeldLocks() []*Lock {
+	return l.state.heldLocks()
+}
@@ -0,0 +1,90 @@
+package gomysqllock
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"strconv"
+)
+
+// Queries run through statements prepared once per locker
+const (
+	isUsedLockQuery = "SELECT COALESCE(IS_USED_LOCK(?), -1)"
+)
+
+// getLockQuery returns the query obtaining the named lock. GET_LOCK and RELEASE_LOCK run on each lock's own connection,
+// where the locker's statements can not be used, so their arguments are inlined rather than prepared: they take a
+// single round trip whether or not the driver interpolates parameters.
+func getLockQuery(name string, timeout int) string {
+	return "SELECT COALESCE(GET_LOCK(" + lockNameLiteral(name) + ", " + strconv.Itoa(timeout) + "), 2), CONNECTION_ID()"
+}
+
+// releaseLockQuery returns the query releasing the named lock, see getLockQuery
+func releaseLockQuery(name string) string {
+	return "DO RELEASE_LOCK(" + lockNameLiteral(name) + ")"
+}
+
+// lockNameLiteral returns the lock name as an SQL literal. The name's bytes are written in hexadecimal, which needs no
+// escaping whatever the charset and SQL mode, and cast to the connection's charset like a parameter would be.
+func lockNameLiteral(name string) string {
+	return "CAST(X'" + hex.EncodeToString([]byte(name)) + "' AS CHAR)"
+}
+
+// queryRow runs the query through the locker's statement for it. Once the locker is shut down and its statements are
+// closed, the query runs on the pool without one.
+func (l MysqlLocker) queryRow(ctx context.Context, query string, args ...interface{}) (*sql.Row, error) {
+	stmt, err := l.stmt(ctx, query)
+	if err == ErrLockerShutdown {
+		return l.db.QueryRowContext(ctx, query, args...), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return stmt.QueryRowContext(ctx, args...), nil
+}
+
+// stmt returns the statement for the query, preparing it on the locker's pool the first time. database/sql prepares
+// it again on each pool connection it runs on, once, so repeated calls avoid a prepare round trip. It fails with
+// ErrLockerShutdown once the statements are closed, rather than preparing one nothing would close.
+func (l MysqlLocker) stmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	l.state.stmtMu.Lock()
+	stmt, ok := l.state.stmts[query]
+	closed := l.state.stmtsClosed
+	l.state.stmtMu.Unlock()
+	if closed {
+		return nil, ErrLockerShutdown
+	} else if ok {
+		return stmt, nil
+	}
+
+	// prepared without holding the lock, so that a slow prepare only delays the callers it runs for
+	prepared, err := l.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	l.state.stmtMu.Lock()
+	defer l.state.stmtMu.Unlock()
+	if l.state.stmtsClosed {
+		prepared.Close()
+		return nil, ErrLockerShutdown
+	}
+	if stmt, ok := l.state.stmts[query]; ok {
+		// prepared concurrently by another caller
+		prepared.Close()
+		return stmt, nil
+	}
+	l.state.stmts[query] = prepared
+	return prepared, nil
+}
+
+// closeStmts closes the locker's prepared statements
+func (s *lockerState) closeStmts() {
+	s.stmtMu.Lock()
+	defer s.stmtMu.Unlock()
+
+	s.stmtsClosed = true
+	for query, stmt := range s.stmts {
+		stmt.Close()
+		delete(s.stmts, query)
+	}
+}
@@ -0,0 +1,13 @@
+package gomysqllock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockQueries(t *testing.T) {
+	assert.Equal(t, "SELECT COALESCE(GET_LOCK(CAST(X'6a6f6227' AS CHAR), -1), 2), CONNECTION_ID()",
+		getLockQuery("job'", -1), "names shall be inlined without quotes to escape")
+	assert.Equal(t, "DO RELEASE_LOCK(CAST(X'' AS CHAR))", releaseLockQuery(""))
+}
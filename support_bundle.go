@@ -0,0 +1,220 @@
+package gomysqllock
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// supportBundleEvents is the number of most recent history rows included in support bundles
+const supportBundleEvents = 100
+
+// supportBundle is the document written by SupportBundle. Keys are reported as lock names, so obfuscated keys stay
+// obfuscated in bundles.
+type supportBundle struct {
+	GeneratedAt   time.Time
+	ServerVersion string
+	Server        *ServerIdentity
+	// Capabilities tells for each server feature the locker relies on whether it is usable ("ok") or why not
+	Capabilities map[string]string
+	HeldLocks    []supportLock
+	Waiters      []supportWaiter
+	RecentEvents []supportEvent
+	Config       supportConfig
+	// Errors tells which parts of the bundle could not be collected, and why
+	Errors map[string]string
+}
+
+// supportLock is a lock held through the locker
+type supportLock struct {
+	Name      string
+	OwnsConn  bool
+	Healthy   bool
+	Remaining *time.Duration
+}
+
+// supportWaiter is a session waiting for a lock, on any client
+type supportWaiter struct {
+	Name     string
+	ThreadID int64
+}
+
+// supportEvent is a hold recorded in the history table
+type supportEvent struct {
+	Name       string
+	Owner      string
+	ObtainedAt time.Time
+	ReleasedAt *time.Time
+	Outcome    string
+}
+
+// supportConfig is the configuration of the locker, without secrets
+type supportConfig struct {
+	Owner              string
+	RefreshInterval    time.Duration
+	HealthThreshold    time.Duration
+	AdaptiveRefreshMin time.Duration
+	AdaptiveRefreshMax time.Duration
+	ReleaseConcurrency int
+	MetadataTable      string
+	HistoryTable       string
+	ReservationTable   string
+	ReadDB             bool
+	KeyObfuscation     bool
+	KeyPolicies        []string
+	AcquireGuards      int
+	Middlewares        int
+	OpenTxPolicy       OpenTxPolicy
+	Namespace          string
+	Shutdown           bool
+	PoolStats          PoolStats
+}
+
+// SupportBundle writes a JSON document describing the locker and the server it uses, to be attached to bug reports
+// and incident tickets: server version and capabilities, locks held through the locker, sessions waiting for locks,
+// recent history and the locker's configuration. Parts which can not be collected are reported in the document's
+// Errors rather than failing it, only failing to write it is returned as an error.
+func (l MysqlLocker) SupportBundle(ctx context.Context, w io.Writer) error {
+	bundle := supportBundle{
+		GeneratedAt:  time.Now(),
+		Capabilities: make(map[string]string),
+		Errors:       make(map[string]string),
+		Config:       l.supportConfig(),
+	}
+
+	if err := l.db.QueryRowContext(ctx, "SELECT VERSION()").Scan(&bundle.ServerVersion); err != nil {
+		bundle.Errors["ServerVersion"] = err.Error()
+	}
+	if identity, err := l.CurrentServer(ctx); err != nil {
+		bundle.Errors["Server"] = err.Error()
+	} else {
+		bundle.Server = &identity
+	}
+
+	for feature, query := range map[string]string{
+		"IS_USED_LOCK":                      "SELECT IS_USED_LOCK('gomysqllock_probe')",
+		"IS_FREE_LOCK":                      "SELECT IS_FREE_LOCK('gomysqllock_probe')",
+		"@@server_uuid":                     "SELECT @@server_uuid",
+		"performance_schema.metadata_locks": "SELECT COUNT(*) FROM performance_schema.metadata_locks WHERE 1 = 0",
+	} {
+		bundle.Capabilities[feature] = probe(ctx, l.db, query)
+	}
+
+	for _, lock := range l.HeldLocks() {
+		held := supportLock{Name: lock.name, OwnsConn: lock.ownsConn, Healthy: lock.Healthy()}
+		if remaining, bounded := lock.Remaining(); bounded {
+			held.Remaining = &remaining
+		}
+		bundle.HeldLocks = append(bundle.HeldLocks, held)
+	}
+
+	waiters, err := l.supportWaiters(ctx)
+	if err != nil {
+		bundle.Errors["Waiters"] = err.Error()
+	}
+	bundle.Waiters = waiters
+
+	if l.historyTable != "" {
+		events, err := l.supportEvents(ctx)
+		if err != nil {
+			bundle.Errors["RecentEvents"] = err.Error()
+		}
+		bundle.RecentEvents = events
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(bundle); err != nil {
+		return fmt.Errorf("failed to write support bundle: %w", err)
+	}
+	return nil
+}
+
+// probe runs the query and returns "ok", or the error telling why it failed
+func probe(ctx context.Context, db *sql.DB, query string) string {
+	var discard interface{}
+	if err := db.QueryRowContext(ctx, query).Scan(&discard); err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
+
+// supportConfig returns the locker's configuration
+func (l MysqlLocker) supportConfig() supportConfig {
+	live := l.config()
+	config := supportConfig{
+		Owner:              l.owner,
+		RefreshInterval:    live.refreshInterval,
+		HealthThreshold:    live.healthThreshold,
+		AdaptiveRefreshMin: live.adaptiveRefreshMin,
+		AdaptiveRefreshMax: live.adaptiveRefreshMax,
+		ReleaseConcurrency: live.releaseConcurrency,
+		MetadataTable:      l.metadataTable,
+		HistoryTable:       l.historyTable,
+		ReservationTable:   l.reservationTable,
+		ReadDB:             l.readOnlyDB != nil,
+		KeyObfuscation:     l.obfuscationKey != nil,
+		AcquireGuards:      len(l.acquireGuards),
+		Middlewares:        len(l.middlewares),
+		OpenTxPolicy:       l.openTxPolicy,
+		Namespace:          l.namespace,
+		Shutdown:           l.state.isShutdown(),
+		PoolStats:          l.PoolStats(),
+	}
+	for _, rule := range l.keyPolicies {
+		config.KeyPolicies = append(config.KeyPolicies, rule.pattern)
+	}
+	return config
+}
+
+// supportWaiters returns the sessions waiting for a lock on the primary
+func (l MysqlLocker) supportWaiters(ctx context.Context) ([]supportWaiter, error) {
+	rows, err := l.db.QueryContext(ctx, "SELECT OBJECT_NAME, OWNER_THREAD_ID FROM performance_schema.metadata_locks "+
+		"WHERE OBJECT_TYPE = 'USER LEVEL LOCK' AND LOCK_STATUS = 'PENDING'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var waiters []supportWaiter
+	for rows.Next() {
+		var waiter supportWaiter
+		if err := rows.Scan(&waiter.Name, &waiter.ThreadID); err != nil {
+			return waiters, err
+		}
+		waiters = append(waiters, waiter)
+	}
+	return waiters, rows.Err()
+}
+
+// supportEvents returns the most recent holds recorded in the history table
+func (l MysqlLocker) supportEvents(ctx context.Context) ([]supportEvent, error) {
+	rows, err := l.readDB().QueryContext(ctx, fmt.Sprintf(
+		"SELECT lock_key, owner, CAST(UNIX_TIMESTAMP(obtained_at) * 1000000 AS UNSIGNED), "+
+			"CAST(UNIX_TIMESTAMP(released_at) * 1000000 AS UNSIGNED), COALESCE(outcome, '') FROM %s "+
+			"ORDER BY id DESC LIMIT %d", l.historyTable, supportBundleEvents))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []supportEvent
+	for rows.Next() {
+		var event supportEvent
+		var obtainedAt int64
+		var releasedAt sql.NullInt64
+		if err := rows.Scan(&event.Name, &event.Owner, &obtainedAt, &releasedAt, &event.Outcome); err != nil {
+			return events, err
+		}
+		event.ObtainedAt = time.Unix(0, obtainedAt*int64(time.Microsecond))
+		if releasedAt.Valid {
+			released := time.Unix(0, releasedAt.Int64*int64(time.Microsecond))
+			event.ReleasedAt = &released
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,78 @@
+package gomysqllock
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultTopologyInterval is the interval of the checks configured with WithTopologyHook, when the given one is not
+// positive
+const DefaultTopologyInterval = 10 * time.Second
+
+// ServerIdentity identifies the MySQL server the locker's connections reach. UUID is empty on servers without
+// @@server_uuid, like MariaDB.
+type ServerIdentity struct {
+	UUID     string
+	Hostname string
+	ServerID int64
+}
+
+// WithTopologyHook checks every interval which server the locker's connections reach, and calls hook with the
+// previous and the new identity whenever it changes, for example after a failover. Locks held across such a change
+// are lost, but applications may also rely on other assumptions about the primary worth re-validating.
+// The hook is called from a background goroutine, which stops when the locker is shut down. Checks which fail are
+// retried at the next interval. Intervals which are not positive are replaced with DefaultTopologyInterval.
+func WithTopologyHook(interval time.Duration, hook func(old, new ServerIdentity)) lockerOpt {
+	return func(l *MysqlLocker) {
+		if interval <= 0 {
+			interval = DefaultTopologyInterval
+		}
+		l.topologyInterval = interval
+		l.topologyHook = hook
+	}
+}
+
+// CurrentServer returns the identity of the server the locker's connections reach
+func (l MysqlLocker) CurrentServer(ctx context.Context) (ServerIdentity, error) {
+	var identity ServerIdentity
+	err := l.db.QueryRowContext(ctx, "SELECT @@server_uuid, @@hostname, @@server_id").
+		Scan(&identity.UUID, &identity.Hostname, &identity.ServerID)
+	if err != nil {
+		// servers without @@server_uuid are identified by their hostname and server id only
+		identity.UUID = ""
+		err = l.db.QueryRowContext(ctx, "SELECT @@hostname, @@server_id").
+			Scan(&identity.Hostname, &identity.ServerID)
+	}
+	if err != nil {
+		return ServerIdentity{}, fmt.Errorf("could not read server identity: %w", err)
+	}
+	return identity, nil
+}
+
+// watchTopology runs the checks configured with WithTopologyHook until the locker is shut down
+func (l MysqlLocker) watchTopology() {
+	l.state.goroutine(func() {
+		ticker := time.NewTicker(l.topologyInterval)
+		defer ticker.Stop()
+
+		var known *ServerIdentity
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), l.topologyInterval)
+			identity, err := l.CurrentServer(ctx)
+			cancel()
+			if err == nil {
+				if known != nil && *known != identity {
+					l.topologyHook(*known, identity)
+				}
+				known = &identity
+			}
+
+			select {
+			case <-ticker.C:
+			case <-l.state.done:
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,16 @@
+package gomysqllock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithTopologyHook_NonPositiveInterval(t *testing.T) {
+	var locker MysqlLocker
+	WithTopologyHook(0, func(old, new ServerIdentity) {})(&locker)
+	assert.Equal(t, DefaultTopologyInterval, locker.topologyInterval)
+
+	WithTopologyHook(-1, func(old, new ServerIdentity) {})(&locker)
+	assert.Equal(t, DefaultTopologyInterval, locker.topologyInterval)
+}
@@ -0,0 +1,110 @@
+package gomysqllock
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+)
+
+// BoundTx is a transaction which releases its bound lock once it is committed or rolled back
+type BoundTx struct {
+	*sql.Tx
+	lock *Lock
+}
+
+// Commit commits the transaction and then releases the lock, whether or not the commit succeeded
+func (t *BoundTx) Commit() error {
+	err := t.Tx.Commit()
+	releaseErr := t.lock.Release()
+	if err != nil {
+		return err
+	}
+	return releaseErr
+}
+
+// Rollback rolls the transaction back and then releases the lock
+func (t *BoundTx) Rollback() error {
+	err := t.Tx.Rollback()
+	releaseErr := t.lock.Release()
+	if err != nil {
+		return err
+	}
+	return releaseErr
+}
+
+// OpenTxPolicy decides what happens when a lock is obtained from the pool while the caller has an open transaction on
+// the same pool. Each of them pins a connection, which can deadlock small pools.
+type OpenTxPolicy int
+
+const (
+	// OpenTxAllow lets the lock be obtained, this is the default
+	OpenTxAllow OpenTxPolicy = iota
+	// OpenTxWarn calls the warning hook and lets the lock be obtained
+	OpenTxWarn
+	// OpenTxDeny fails obtaining the lock with ErrOpenTx
+	OpenTxDeny
+)
+
+type openTxContextKey struct{}
+
+// TrackedTx is a transaction started with MysqlLocker.BeginTx, so that the locker can detect locks obtained while it
+// is open
+type TrackedTx struct {
+	*sql.Tx
+	db   *sql.DB
+	done int32
+}
+
+// Commit commits the transaction
+func (t *TrackedTx) Commit() error {
+	atomic.StoreInt32(&t.done, 1)
+	return t.Tx.Commit()
+}
+
+// Rollback rolls the transaction back
+func (t *TrackedTx) Rollback() error {
+	atomic.StoreInt32(&t.done, 1)
+	return t.Tx.Rollback()
+}
+
+// WithOpenTxPolicy sets what happens when a lock is obtained from the pool with a context returned by BeginTx while
+// its transaction is still open. onWarn is called with the key under OpenTxWarn.
+// ObtainOnConn is not subject to the policy, it is the way to lock from within a transaction.
+func WithOpenTxPolicy(policy OpenTxPolicy, onWarn func(key string)) lockerOpt {
+	return func(l *MysqlLocker) {
+		l.openTxPolicy = policy
+		l.openTxWarn = onWarn
+	}
+}
+
+// BeginTx starts a transaction on the locker's pool which is tracked for the open transaction policy. The returned
+// context must be used for the work done within the transaction, including obtaining locks.
+func (l MysqlLocker) BeginTx(ctx context.Context, opts *sql.TxOptions) (context.Context, *TrackedTx, error) {
+	tx, err := l.db.BeginTx(ctx, opts)
+	if err != nil {
+		return ctx, nil, err
+	}
+
+	trackedTx := &TrackedTx{Tx: tx, db: l.db}
+	return context.WithValue(ctx, openTxContextKey{}, trackedTx), trackedTx, nil
+}
+
+// checkOpenTx applies the open transaction policy
+func (l MysqlLocker) checkOpenTx(ctx context.Context, key string) error {
+	if l.openTxPolicy == OpenTxAllow {
+		return nil
+	}
+
+	tx, ok := ctx.Value(openTxContextKey{}).(*TrackedTx)
+	if !ok || tx.db != l.db || atomic.LoadInt32(&tx.done) == 1 {
+		return nil
+	}
+
+	if l.openTxPolicy == OpenTxDeny {
+		return ErrOpenTx
+	}
+	if l.openTxWarn != nil {
+		l.openTxWarn(key)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package gomysqllock
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// LockChangeType tells what happened to a lock
+type LockChangeType string
+
+// Types of lock changes, the ended ones match the outcomes recorded in the history table
+const (
+	LockObtained LockChangeType = "obtained"
+	LockReleased LockChangeType = OutcomeReleased
+	LockLost     LockChangeType = OutcomeLost
+)
+
+// LockChange is a change of a lock's state, as recorded in the history table by any locker using it
+type LockChange struct {
+	Key   string
+	Owner string
+	Type  LockChangeType
+	At    time.Time
+}
+
+// watchTimeFormat formats the cursor's timestamps as strings MySQL compares exactly, whatever the DSN's parseTime is.
+// Being fixed width, they also compare like the times they stand for.
+const watchTimeFormat = "%Y-%m-%d %H:%i:%s.%f"
+
+// watchTimeLayout parses the timestamps formatted with watchTimeFormat
+const watchTimeLayout = "2006-01-02 15:04:05.000000"
+
+// watchSettleWindow is how long after being recorded a change is assumed to be committed. AUTO_INCREMENT ids and
+// timestamps are assigned when statements run, not when they commit, so a change may become visible after newer ones:
+// the changes recorded within the window are read again by every poll, and those already delivered are skipped.
+const watchSettleWindow = 5 * time.Second
+
+// watchCursor marks how far the history table has been read. Rows up to obtainedID, and rows released up to endedAt,
+// are settled. Rows read past them are tracked by id, along with when they were recorded, until they settle.
+type watchCursor struct {
+	obtainedID int64
+	endedAt    string
+	obtained   map[int64]int64
+	ended      map[int64]string
+}
+
+// Watch polls the history table every interval and sends the lock changes recorded since the call on the returned
+// channel, ordered by time within each poll. It works across processes, as long as they record history in the same
+// table. Changes committed late, after newer ones, are still delivered as long as they commit within 5 seconds of being
+// recorded; only changes of writers stalled for longer are missed. The channel is closed once ctx is done or the
+// locker is shut down. Polls which fail are retried at the next interval. The interval must be positive, otherwise
+// ErrInvalidInterval is returned.
+func (l MysqlLocker) Watch(ctx context.Context, interval time.Duration) (<-chan LockChange, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+	if l.historyTable == "" {
+		return nil, ErrHistoryNotConfigured
+	}
+
+	cursor := watchCursor{obtained: make(map[int64]int64), ended: make(map[int64]string)}
+	err := l.readDB().QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT COALESCE(MAX(id), 0), DATE_FORMAT(CURRENT_TIMESTAMP(6), '%s') FROM %s",
+		watchTimeFormat, l.historyTable)).Scan(&cursor.obtainedID, &cursor.endedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not read lock history: %w", err)
+	}
+
+	changes := make(chan LockChange)
+	started := l.state.goroutine(func() {
+		defer close(changes)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				polled, err := l.pollChanges(ctx, &cursor)
+				if err != nil {
+					continue
+				}
+				for _, change := range polled {
+					select {
+					case changes <- change:
+					case <-ctx.Done():
+						return
+					case <-l.state.done:
+						return
+					}
+				}
+			case <-ctx.Done():
+				return
+			case <-l.state.done:
+				return
+			}
+		}
+	})
+	if !started {
+		return nil, ErrLockerShutdown
+	}
+
+	return changes, nil
+}
+
+// pollChanges reads the changes recorded after the cursor which were not delivered yet, and advances it
+func (l MysqlLocker) pollChanges(ctx context.Context, cursor *watchCursor) ([]LockChange, error) {
+	var changes []LockChange
+	obtained := make(map[int64]int64)
+	ended := make(map[int64]string)
+
+	rows, err := l.readDB().QueryContext(ctx, fmt.Sprintf(
+		"SELECT id, lock_key, owner, CAST(UNIX_TIMESTAMP(obtained_at) * 1000000 AS UNSIGNED) FROM %s "+
+			"WHERE id > ? ORDER BY id", l.historyTable), cursor.obtainedID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		change := LockChange{Type: LockObtained}
+		var id, at int64
+		if err := rows.Scan(&id, &change.Key, &change.Owner, &at); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		obtained[id] = at
+		if _, delivered := cursor.obtained[id]; delivered {
+			continue
+		}
+		change.At = time.Unix(0, at*int64(time.Microsecond))
+		changes = append(changes, change)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = l.readDB().QueryContext(ctx, fmt.Sprintf(
+		"SELECT id, lock_key, owner, outcome, DATE_FORMAT(released_at, '%s'), "+
+			"CAST(UNIX_TIMESTAMP(released_at) * 1000000 AS UNSIGNED) FROM %s "+
+			"WHERE released_at > ? ORDER BY released_at, id",
+		watchTimeFormat, l.historyTable), cursor.endedAt)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var change LockChange
+		var id, at int64
+		var endedAt string
+		if err := rows.Scan(&id, &change.Key, &change.Owner, &change.Type, &endedAt, &at); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ended[id] = endedAt
+		if _, delivered := cursor.ended[id]; delivered {
+			continue
+		}
+		change.At = time.Unix(0, at*int64(time.Microsecond))
+		changes = append(changes, change)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].At.Before(changes[j].At) })
+	cursor.settle(obtained, ended)
+	return changes, nil
+}
+
+// settle replaces the rows tracked by the cursor with the ones read by the last poll, and moves the cursor past those
+// recorded longer than the settle window before the newest one
+func (c *watchCursor) settle(obtained map[int64]int64, ended map[int64]string) {
+	var newest int64
+	for _, at := range obtained {
+		if at > newest {
+			newest = at
+		}
+	}
+	settledAt := newest - watchSettleWindow.Microseconds()
+	for id, at := range obtained {
+		if at <= settledAt && id > c.obtainedID {
+			c.obtainedID = id
+		}
+	}
+	for id := range obtained {
+		if id <= c.obtainedID {
+			delete(obtained, id)
+		}
+	}
+	c.obtained = obtained
+
+	newestEnded := c.endedAt
+	for _, at := range ended {
+		if at > newestEnded {
+			newestEnded = at
+		}
+	}
+	if t, err := time.Parse(watchTimeLayout, newestEnded); err == nil {
+		if settled := t.Add(-watchSettleWindow).Format(watchTimeLayout); settled > c.endedAt {
+			c.endedAt = settled
+		}
+	}
+	for id, at := range ended {
+		if at <= c.endedAt {
+			delete(ended, id)
+		}
+	}
+	c.ended = ended
+}
@@ -0,0 +1,27 @@
+package gomysqllock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchCursor_Settle(t *testing.T) {
+	second := int64(1000000)
+	cursor := watchCursor{obtainedID: 1, endedAt: "2020-01-01 00:00:00.000000"}
+
+	cursor.settle(map[int64]int64{2: 10 * second, 4: 12 * second}, map[int64]string{
+		2: "2020-01-01 00:00:02.000000",
+		3: "2020-01-01 00:00:09.000000",
+	})
+	assert.EqualValues(t, 1, cursor.obtainedID, "rows recorded within the window shall not settle")
+	assert.Len(t, cursor.obtained, 2)
+	assert.Equal(t, "2020-01-01 00:00:04.000000", cursor.endedAt)
+	assert.Equal(t, map[int64]string{3: "2020-01-01 00:00:09.000000"}, cursor.ended,
+		"rows released before the settled time shall no longer be tracked")
+
+	cursor.settle(map[int64]int64{2: 10 * second, 3: 11 * second, 4: 12 * second, 5: 16 * second}, nil)
+	assert.EqualValues(t, 3, cursor.obtainedID, "rows recorded before the window shall settle, late ones included")
+	assert.Equal(t, map[int64]int64{4: 12 * second, 5: 16 * second}, cursor.obtained)
+	assert.Equal(t, "2020-01-01 00:00:04.000000", cursor.endedAt, "the settled time shall never move back")
+}